@@ -3,24 +3,59 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
 )
 
+// scratchMountPath is where the in-memory scratch volume is mounted.
+const scratchMountPath = "scratch"
+
 // App struct
 type App struct {
-	ctx     context.Context
-	storage *Storage
+	ctx       context.Context
+	storage   *Storage
+	tasks     *Dispatcher
+	shares    *ShareStore
+	preview   *PreviewServer
+	removals  *RemoveTasks
+	histories *Histories
+	history   *History
+	access    *AccessControl
+	davMutex  sync.Mutex
+	davServer *http.Server
 }
 
 // NewApp creates a new App application struct
 func NewApp(path string) *App {
-	if path == "" || path == "/" {
-		return &App{storage: NewStorage(NewRootFS())}
+	tasks := NewDispatcher(4, 64, true)
+	storage := NewStorage(NewRootFS())
+	storage.Mount(scratchMountPath, NewMemFS())
+	if err := LoadMimeConfig(defaultMimeConfigFile()); err != nil {
+		log.Println("Failed to load MIME types ", err)
+	}
+	shares, err := NewShareStore(defaultShareStoreFile())
+	if err != nil {
+		log.Println("Failed to load shares ", err)
+		shares, _ = NewShareStore("")
 	}
-	return &App{storage: NewStorage(NewRootFS())}
+	histories := NewHistories()
+	return &App{storage: storage, tasks: tasks, shares: shares, preview: NewPreviewServer(), removals: NewRemoveTasks(),
+		histories: histories, history: histories.Get("")}
 	// return &App{storage: NewStorage(NewWritableDirFS(path))}
 }
 
+// withSession returns an App working on the storage and the operation history of the non-admin user s. It shares the tasks with a.
+func (a *App) withSession(s *Session) *App {
+	return &App{ctx: a.ctx, storage: s.Storage, tasks: a.tasks, shares: a.shares, preview: a.preview, removals: a.removals,
+		histories: a.histories, history: a.histories.Get(s.User)}
+}
+
 // startup is called at application startup
 func (a *App) startup(ctx context.Context) {
 	// Perform your setup here
@@ -28,7 +63,7 @@ func (a *App) startup(ctx context.Context) {
 }
 
 // domReady is called after front-end resources have been loaded
-func (a App) domReady(ctx context.Context) {
+func (a *App) domReady(ctx context.Context) {
 	// Add your action here
 }
 
@@ -59,14 +94,437 @@ func (a *App) GetFiles(path string, offset, limit int) *FileList {
 	return r
 }
 
+// GetMimeType returns the type of the file detected from its content, or from the extension if the content is not recognized.
+func (a *App) GetMimeType(path string) string {
+	return MimeTypeOf(a.storage.v, path, nil, true)
+}
+
+// GetFileAssociation returns the type of the file and how the UI should open it.
+func (a *App) GetFileAssociation(path string) *FileAssociation {
+	return GetFileAssociation(MimeTypeOf(a.storage.v, path, nil, false))
+}
+
 func (a *App) Mkdir(path string) bool {
-	return a.storage.v.Mkdir(path, 0666) != nil
+	err := a.storage.v.Mkdir(path, 0666)
+	if err == nil {
+		a.history.Add("mkdir", NewOperationItem(a.storage.v, "", path))
+	}
+	return err != nil
 }
 
 func (a *App) Rename(path1, path2 string) bool {
-	return a.storage.v.Rename(path1, path2) != nil
+	err := a.storage.v.Rename(path1, path2)
+	if err == nil {
+		a.history.Add(operationKind(path1, path2), NewOperationItem(a.storage.v, path1, path2))
+	}
+	return err != nil
+}
+
+// PreviewBulkRename returns the new names of files by rule with conflicts. It returns nil if the rule is invalid.
+func (a *App) PreviewBulkRename(files []string, rule *RenameRule) []*RenamePreview {
+	previews, err := PreviewBulkRename(a.storage.v, files, rule)
+	if err != nil {
+		log.Println(err)
+	}
+	return previews
+}
+
+// BulkRename renames files by rule. Nothing is renamed if PreviewBulkRename reports any conflict.
+func (a *App) BulkRename(files []string, rule *RenameRule) bool {
+	previews, err := BulkRename(a.storage.v, files, rule)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	items := []*OperationItem{}
+	for _, p := range previews {
+		if p.newPath() != p.Path {
+			items = append(items, NewOperationItem(a.storage.v, p.Path, p.newPath()))
+		}
+	}
+	a.history.Add("bulkrename", items...)
+	return true
+}
+
+// TransferFiles copies or moves (mode is "copy" or "move") files into the folder dir.
+// Files on other volumes are moved by copying and removing them.
+func (a *App) TransferFiles(dir string, files []string, mode string) bool {
+	if mode != "copy" && mode != "move" {
+		log.Println("invalid mode", mode)
+		return false
+	}
+	items := []*OperationItem{}
+	ok := true
+	for _, f := range files {
+		dst := path.Join(dir, path.Base(f))
+		var err error
+		if exists(a.storage.v, dst) {
+			err = &fs.PathError{Op: mode, Path: dst, Err: fs.ErrExist}
+		} else if mode == "copy" {
+			err = CopyAll(a.storage.v, f, dst)
+		} else {
+			err = MoveAll(a.storage.v, f, dst)
+		}
+		if err != nil {
+			log.Println(f, err)
+			ok = false
+			continue
+		}
+		items = append(items, NewOperationItem(a.storage.v, f, dst))
+	}
+	a.history.Add(mode, items...)
+	return ok
 }
 
 func (a *App) Remove(path string) bool {
 	return a.storage.v.Remove(path) != nil
 }
+
+// RemoveAll removes the file or folder recursively in the background and returns the task ID for GetRemoveStatus.
+// It returns "" if the task can't be started.
+func (a *App) RemoveAll(path string) string {
+	id, err := a.removals.Start(a.tasks, a.storage.v, path)
+	if err != nil {
+		log.Println(path, err)
+	}
+	return id
+}
+
+// GetRemoveStatus returns the progress of RemoveAll. Failed items are listed in the report when it is done.
+func (a *App) GetRemoveStatus(id string) *RemoveReport {
+	return a.removals.Report(id)
+}
+
+func (a *App) CancelRemove(id string) bool {
+	return a.removals.Cancel(id)
+}
+
+// MoveToTrash moves the file to the trash. Use Remove if the folder caps don't include "trash".
+func (a *App) MoveToTrash(path string) bool {
+	err := ErrInvalidOp
+	realPath, _ := RealPath(a.storage.v, path)
+	if t, ok := a.storage.v.(TrashFS); ok {
+		err = t.MoveToTrash(path)
+	}
+	if err != nil {
+		log.Println(path, err)
+		return false
+	}
+	item := NewOperationItem(a.storage.v, path, "")
+	item.realPath = realPath
+	a.history.Add("trash", item)
+	return true
+}
+
+// Undo reverses the latest operation by Rename, Mkdir, MoveToTrash or TransferFiles.
+// It fails if the files are changed after the operation.
+func (a *App) Undo() *Operation {
+	op, err := a.history.Undo(a.storage.v)
+	if err != nil {
+		log.Println("Failed to undo ", err)
+	}
+	return op
+}
+
+// Redo performs the latest undone operation again.
+func (a *App) Redo() *Operation {
+	op, err := a.history.Redo(a.storage.v)
+	if err != nil {
+		log.Println("Failed to redo ", err)
+	}
+	return op
+}
+
+// GetOperations returns the recent operations from the latest. Undone operations which can be redone come first.
+func (a *App) GetOperations() []*Operation {
+	return a.history.Recent()
+}
+
+// ListTrash returns the items in the trash of the desktop user.
+func (a *App) ListTrash() []*TrashItem {
+	items, err := ListTrash()
+	if err != nil {
+		log.Println(err)
+		return []*TrashItem{}
+	}
+	return items
+}
+
+// RestoreFromTrash moves the trash item back to the original path.
+func (a *App) RestoreFromTrash(id string) bool {
+	if err := RestoreFromTrash(id); err != nil {
+		log.Println(id, err)
+		return false
+	}
+	return true
+}
+
+func (a *App) EmptyTrash() bool {
+	if err := EmptyTrash(); err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// ConvertImage writes a resized copy of srcPath to dstPath in the background.
+// format is "jpeg" or "png". Zero maxWidth/maxHeight keeps the original size.
+// dstPath must not exist.
+func (a *App) ConvertImage(srcPath, dstPath string, maxWidth, maxHeight int, format string) bool {
+	opt := &ImageConvertOptions{MaxWidth: maxWidth, MaxHeight: maxHeight, Format: format}
+	if _, err := NormalizeImageFormat(format); err != nil {
+		log.Println(srcPath, err)
+		return false
+	}
+	if path.Clean(srcPath) == path.Clean(dstPath) {
+		log.Println("Can not overwrite the source image ", srcPath)
+		return false
+	}
+	task := a.tasks.TryAddFunc(func() {
+		if err := a.convertImage(srcPath, dstPath, opt); err != nil {
+			log.Println("Failed to convert image ", srcPath, err)
+		}
+	}, "convert:"+dstPath)
+	return task != nil
+}
+
+func (a *App) convertImage(srcPath, dstPath string, opt *ImageConvertOptions) error {
+	in, err := a.storage.v.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Existing files (including the source) are not overwritten.
+	out, err := a.storage.v.OpenWriter(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	err = ConvertImage(in, out, opt)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		a.storage.v.Remove(dstPath)
+	}
+	return err
+}
+
+// MountSFTP connects to a remote host and mounts it at name.
+func (a *App) MountSFTP(name string, conf *SFTPConfig) bool {
+	v, err := NewSFTPFS(conf)
+	if err != nil {
+		log.Println("Failed to connect ", conf.Host, err)
+		return false
+	}
+	if err := a.storage.Mount(name, v); err != nil {
+		v.Close()
+		log.Println(name, err)
+		return false
+	}
+	return true
+}
+
+// MountWebDAV mounts a WebDAV server at name.
+func (a *App) MountWebDAV(name string, conf *WebDAVConfig) bool {
+	v, err := NewWebDAVFS(conf)
+	if err != nil {
+		log.Println("Failed to connect ", conf.URL, err)
+		return false
+	}
+	if err := a.storage.Mount(name, v); err != nil {
+		log.Println(name, err)
+		return false
+	}
+	return true
+}
+
+// MountS3 mounts an S3 compatible bucket at name.
+func (a *App) MountS3(name string, conf *S3Config) bool {
+	v, err := NewS3FS(conf)
+	if err != nil {
+		log.Println("Failed to connect ", conf.Bucket, err)
+		return false
+	}
+	if err := a.storage.Mount(name, v); err != nil {
+		log.Println(name, err)
+		return false
+	}
+	return true
+}
+
+// MountHTTPIndex mounts a HTTP server with directory listings at name as a read-only volume.
+func (a *App) MountHTTPIndex(name string, conf *HTTPIndexConfig) bool {
+	v, err := NewHTTPIndexFS(conf)
+	if err != nil {
+		log.Println(conf.URL, err)
+		return false
+	}
+	if _, err := v.ReadDir("."); err != nil {
+		log.Println("Failed to connect ", conf.URL, err)
+		return false
+	}
+	if err := a.storage.Mount(name, v); err != nil {
+		log.Println(name, err)
+		return false
+	}
+	return true
+}
+
+func (a *App) Unmount(name string) bool {
+	if err := a.storage.Unmount(name); err != nil {
+		log.Println(name, err)
+		return false
+	}
+	return true
+}
+
+// StartWebDAVServer shares the storage over WebDAV at addr (e.g. "127.0.0.1:8080"). Files can be changed only if writable is true.
+// Users are authenticated by -access. Without it, addr must be a loopback address.
+func (a *App) StartWebDAVServer(addr string, writable bool) bool {
+	a.davMutex.Lock()
+	defer a.davMutex.Unlock()
+	if a.davServer != nil {
+		log.Println("WebDAV server is already running")
+		return false
+	}
+	if a.access == nil && !isLoopbackAddr(addr) {
+		log.Println("WebDAV server requires -access to listen on ", addr)
+		return false
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Println(addr, err)
+		return false
+	}
+	var handler http.Handler = NewWebDAVHandler(a.storage.v, "", !writable)
+	if a.access != nil {
+		handler = a.access.Handler(handler)
+	}
+	srv := &http.Server{Handler: handler}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Println("WebDAV server ", err)
+		}
+	}()
+	log.Println("WebDAV server is listening on ", l.Addr())
+	a.davServer = srv
+	return true
+}
+
+func (a *App) StopWebDAVServer() bool {
+	a.davMutex.Lock()
+	defer a.davMutex.Unlock()
+	if a.davServer == nil {
+		return false
+	}
+	err := a.davServer.Close()
+	a.davServer = nil
+	return err == nil
+}
+
+// CreateShare creates a read-only link to a file or folder. The link is volume?share=<token>.
+// expireSeconds <= 0 means no expiry and maxDownloads <= 0 means unlimited downloads.
+func (a *App) CreateShare(path string, expireSeconds, maxDownloads int, allowList bool) *Share {
+	if CapsAt(a.storage.v, path)&Read == 0 {
+		log.Println(path, "is not readable")
+		return nil
+	}
+	if _, err := a.storage.v.Stat(path); err != nil {
+		log.Println(path, err)
+		return nil
+	}
+	sh, err := a.shares.Create(path, time.Duration(expireSeconds)*time.Second, maxDownloads, allowList)
+	if err != nil {
+		log.Println("Failed to create share ", path, err)
+		return nil
+	}
+	return sh
+}
+
+func (a *App) GetShares() []*Share {
+	return a.shares.List()
+}
+
+func (a *App) RevokeShare(id string) bool {
+	if err := a.shares.Revoke(id); err != nil {
+		log.Println(id, err)
+		return false
+	}
+	return true
+}
+
+// PreviewURL returns a URL to render the HTML file at path on an isolated origin.
+// The preview server is started on a local port if it is not running.
+func (a *App) PreviewURL(path string) string {
+	if _, err := a.storage.v.Stat(path); err != nil {
+		log.Println(path, err)
+		return ""
+	}
+	if err := a.preview.Start("127.0.0.1:0"); err != nil {
+		log.Println("Failed to start preview server ", err)
+		return ""
+	}
+	u, err := a.preview.URL(a.storage, path)
+	if err != nil {
+		log.Println(path, err)
+		return ""
+	}
+	return u
+}
+
+// localPath returns the path of a local file, which can be passed to desktop applications.
+func (a *App) localPath(path string) (string, error) {
+	if _, err := a.storage.v.Stat(path); err != nil {
+		return "", err
+	}
+	p, ok := RealPath(a.storage.v, path)
+	if !ok {
+		return "", &fs.PathError{Op: "open", Path: path, Err: ErrNotLocalFile}
+	}
+	return p, nil
+}
+
+// OpenExternal opens the file with the default application of the desktop.
+func (a *App) OpenExternal(path string) bool {
+	p, err := a.localPath(path)
+	if err == nil {
+		err = OpenExternal(p)
+	}
+	if err != nil {
+		log.Println(path, err)
+		return false
+	}
+	return true
+}
+
+// OpenWith opens the file with the application appID returned by GetApplications.
+func (a *App) OpenWith(path, appID string) bool {
+	p, err := a.localPath(path)
+	if err == nil {
+		err = OpenWith(p, appID)
+	}
+	if err != nil {
+		log.Println(path, err)
+		return false
+	}
+	return true
+}
+
+// RevealInFolder shows the file in the file manager of the desktop.
+func (a *App) RevealInFolder(path string) bool {
+	p, err := a.localPath(path)
+	if err == nil {
+		err = RevealInFolder(p)
+	}
+	if err != nil {
+		log.Println(path, err)
+		return false
+	}
+	return true
+}
+
+// GetApplications returns applications which can open files of mimeType.
+func (a *App) GetApplications(mimeType string) []*DesktopApp {
+	return DesktopAppsForType(mimeType)
+}
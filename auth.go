@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccessConfig is the authentication and access control settings of the server mode.
+//
+//	{
+//	  "token": "secret", "tokenUser": "admin",
+//	  "htpasswd": "/etc/file-manager/htpasswd",
+//	  "admins": ["admin"],
+//	  "rules": [
+//	    {"user": "alice", "path": "home/alice", "caps": ["all"]},
+//	    {"user": "*", "path": "pub", "caps": ["read", "stat"]}
+//	  ]
+//	}
+type AccessConfig struct {
+	// Token authenticates requests as TokenUser with "Authorization: Bearer <token>", or ?token=<token> once per browser.
+	Token     string `json:"token,omitempty"`
+	TokenUser string `json:"tokenUser,omitempty"`
+	// Htpasswd is a file of "user:hash" lines for basic auth. bcrypt, $apr1$ and {SHA} hashes are supported.
+	Htpasswd string `json:"htpasswd,omitempty"`
+	// Admins can access everything and call all App methods.
+	Admins []string           `json:"admins,omitempty"`
+	Rules  []AccessRuleConfig `json:"rules,omitempty"`
+}
+
+type AccessRuleConfig struct {
+	User string   `json:"user"` // "*" matches any authenticated user
+	Path string   `json:"path"`
+	Caps []string `json:"caps"`
+}
+
+func LoadAccessConfig(file string) (*AccessConfig, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var conf AccessConfig
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// Authenticator identifies the user of a request.
+type Authenticator interface {
+	Authenticate(req *http.Request) (user string, ok bool)
+}
+
+// Session is the authenticated user of a request and the storage visible to the user.
+type Session struct {
+	User    string
+	Admin   bool
+	Storage *Storage
+}
+
+type sessionKey struct{}
+
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// AccessControl authenticates requests and attaches a Session to the request context.
+type AccessControl struct {
+	auth    []Authenticator
+	basic   bool
+	admins  map[string]bool
+	rules   map[string][]AccessRule
+	storage *Storage
+}
+
+func NewAccessControl(conf *AccessConfig, storage *Storage) (*AccessControl, error) {
+	ac := &AccessControl{admins: map[string]bool{}, rules: map[string][]AccessRule{}, storage: storage}
+	if conf.Token != "" {
+		user := conf.TokenUser
+		if user == "" {
+			user = "token"
+		}
+		ac.auth = append(ac.auth, &tokenAuth{token: conf.Token, user: user})
+	}
+	if conf.Htpasswd != "" {
+		h, err := NewHtpasswdAuth(conf.Htpasswd)
+		if err != nil {
+			return nil, err
+		}
+		ac.auth = append(ac.auth, h)
+		ac.basic = true
+	}
+	if len(ac.auth) == 0 {
+		return nil, errors.New("no authentication method")
+	}
+	for _, u := range conf.Admins {
+		ac.admins[u] = true
+	}
+	for _, r := range conf.Rules {
+		caps, err := ParseCapabilities(r.Caps)
+		if err != nil {
+			return nil, err
+		}
+		ac.rules[r.User] = append(ac.rules[r.User], AccessRule{Path: r.Path, Caps: caps})
+	}
+	return ac, nil
+}
+
+func (ac *AccessControl) session(user string) *Session {
+	if ac.admins[user] {
+		return &Session{User: user, Admin: true, Storage: ac.storage}
+	}
+	rules := append(append([]AccessRule{}, ac.rules["*"]...), ac.rules[user]...)
+	return &Session{User: user, Storage: ac.storage.Restrict(rules)}
+}
+
+func (ac *AccessControl) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		for _, a := range ac.auth {
+			if t, ok := a.(*tokenAuth); ok && t.serveLogin(res, req) {
+				return
+			}
+			if user, ok := a.Authenticate(req); ok {
+				ctx := context.WithValue(req.Context(), sessionKey{}, ac.session(user))
+				next.ServeHTTP(res, req.WithContext(ctx))
+				return
+			}
+		}
+		if ac.basic {
+			res.Header().Set("WWW-Authenticate", `Basic realm="file-manager", charset="UTF-8"`)
+		}
+		http.Error(res, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+const tokenCookieName = "file_manager_token"
+
+type tokenAuth struct {
+	token string
+	user  string
+}
+
+func (a *tokenAuth) match(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+func (a *tokenAuth) Authenticate(req *http.Request) (string, bool) {
+	if h := req.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") && a.match(h[len("Bearer "):]) {
+		return a.user, true
+	}
+	if c, err := req.Cookie(tokenCookieName); err == nil && a.match(c.Value) {
+		return a.user, true
+	}
+	return "", false
+}
+
+// serveLogin sets the token cookie if ?token= is valid and redirects to the page without the token.
+func (a *tokenAuth) serveLogin(res http.ResponseWriter, req *http.Request) bool {
+	q := req.URL.Query()
+	if !q.Has("token") || !a.match(q.Get("token")) {
+		return false
+	}
+	http.SetCookie(res, &http.Cookie{Name: tokenCookieName, Value: a.token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	q.Del("token")
+	u := *req.URL
+	u.RawQuery = q.Encode()
+	http.Redirect(res, req, u.String(), http.StatusFound)
+	return true
+}
+
+// htpasswdAuth checks basic auth credentials against a htpasswd file. The file is reloaded when modified.
+type htpasswdAuth struct {
+	file    string
+	mutex   sync.Mutex
+	modTime time.Time
+	users   map[string]string
+}
+
+func NewHtpasswdAuth(file string) (*htpasswdAuth, error) {
+	a := &htpasswdAuth{file: file}
+	if _, err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *htpasswdAuth) load() (map[string]string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	st, err := os.Stat(a.file)
+	if err != nil {
+		return nil, err
+	}
+	if a.users != nil && st.ModTime().Equal(a.modTime) {
+		return a.users, nil
+	}
+	f, err := os.Open(a.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	users := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if user, hash, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, "#") {
+			users[user] = hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	a.users, a.modTime = users, st.ModTime()
+	return users, nil
+}
+
+func (a *htpasswdAuth) Authenticate(req *http.Request) (string, bool) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	users, err := a.load()
+	if err != nil {
+		log.Println("htpasswd ", err)
+		return "", false
+	}
+	hash, ok := users[user]
+	return user, ok && checkPasswordHash(hash, password)
+}
+
+func checkPasswordHash(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2y$") || strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(hash[len("$apr1$"):], "$")
+		return subtle.ConstantTimeCompare([]byte(apr1MD5(password, salt)), []byte(hash)) == 1
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(sum[:])), []byte(hash[len("{SHA}"):])) == 1
+	}
+	return false
+}
+
+// apr1MD5 is the default hash of Apache htpasswd.
+func apr1MD5(password, salt string) string {
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+	alt := md5.Sum([]byte(password + salt + password))
+	ctx := md5.New()
+	ctx.Write([]byte(password + "$apr1$" + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		ctx.Write(alt[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+	for i := 0; i < 1000; i++ {
+		c := md5.New()
+		if i&1 != 0 {
+			c.Write(pw)
+		} else {
+			c.Write(final)
+		}
+		if i%3 != 0 {
+			c.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			c.Write(pw)
+		}
+		if i&1 != 0 {
+			c.Write(final)
+		} else {
+			c.Write(pw)
+		}
+		final = c.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString("$apr1$" + salt + "$")
+	to64 := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			b.WriteByte(itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		to64(uint32(final[g[0]])<<16|uint32(final[g[1]])<<8|uint32(final[g[2]]), 4)
+	}
+	to64(uint32(final[11]), 2)
+	return b.String()
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var ErrRenameConflict = errors.New("rename conflict")
+
+// RenameRule is how BulkRename changes the names of files. The steps are applied in the order of the fields.
+//
+// Replace, Prefix and Suffix can contain tokens:
+// {name} is the original name without the extension, {n} is the sequence number,
+// {date} and {time} are the date taken in the EXIF ("20060102" and "150405"), or the modified time.
+type RenameRule struct {
+	// Find is a regular expression replaced in the name without the extension. Replace can refer to groups by $1.
+	Find    string `json:"find,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	// Case is "lower", "upper" or "title".
+	Case   string `json:"case,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// Suffix is inserted before the extension.
+	Suffix string `json:"suffix,omitempty"`
+	// Extension replaces the extension if not empty. "." removes it.
+	Extension string `json:"extension,omitempty"`
+	// {n} starts from SeqStart and increases by SeqStep (1 if 0) in the order of the files. It is padded with zeros to SeqPad digits.
+	SeqStart int `json:"seqStart,omitempty"`
+	SeqStep  int `json:"seqStep,omitempty"`
+	SeqPad   int `json:"seqPad,omitempty"`
+}
+
+// RenamePreview is the new name of a file. Conflict is not empty if the file can not be renamed.
+type RenamePreview struct {
+	Path     string `json:"path"`
+	NewName  string `json:"newName"`
+	Conflict string `json:"conflict,omitempty"`
+}
+
+func (p *RenamePreview) newPath() string {
+	return path.Join(path.Dir(p.Path), p.NewName)
+}
+
+func splitExt(name string, isDir bool) (string, string) {
+	ext := path.Ext(name)
+	if isDir || ext == name {
+		return name, ""
+	}
+	return name[:len(name)-len(ext)], ext
+}
+
+func applyCase(s, c string) string {
+	switch c {
+	case "lower":
+		return strings.ToLower(s)
+	case "upper":
+		return strings.ToUpper(s)
+	case "title":
+		prev := ' '
+		return strings.Map(func(r rune) rune {
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && prev != '\'' {
+				r = unicode.ToUpper(r)
+			} else {
+				r = unicode.ToLower(r)
+			}
+			prev = r
+			return r
+		}, s)
+	}
+	return s
+}
+
+type renameContext struct {
+	v     Volume
+	name  string
+	seq   int
+	pad   int
+	info  fs.FileInfo
+	taken *time.Time
+}
+
+func (c *renameContext) dateTaken() time.Time {
+	if c.taken == nil {
+		t := c.info.ModTime()
+		if f, err := c.v.Open(c.name); err == nil {
+			if d, err := ReadDateTaken(f); err == nil {
+				t = d
+			}
+			f.Close()
+		}
+		c.taken = &t
+	}
+	return *c.taken
+}
+
+// expand replaces the tokens in s. If template is true, s is a replacement of regexp and "$" in the values is escaped.
+func (c *renameContext) expand(s, stem string, template bool) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	return tokenPattern.ReplaceAllStringFunc(s, func(token string) string {
+		switch token {
+		case "{name}":
+			if template {
+				return strings.ReplaceAll(stem, "$", "$$")
+			}
+			return stem
+		case "{n}":
+			return fmt.Sprintf("%0*d", c.pad, c.seq)
+		case "{date}":
+			return c.dateTaken().Format("20060102")
+		case "{time}":
+			return c.dateTaken().Format("150405")
+		}
+		return token
+	})
+}
+
+var tokenPattern = regexp.MustCompile(`\{[a-z]+\}`)
+
+func (r *RenameRule) newName(c *renameContext, find *regexp.Regexp) string {
+	orig, ext := splitExt(path.Base(c.name), c.info.IsDir())
+	stem := orig
+	if find != nil {
+		stem = find.ReplaceAllString(stem, c.expand(r.Replace, orig, true))
+	}
+	stem = applyCase(stem, r.Case)
+	stem = c.expand(r.Prefix, orig, false) + stem + c.expand(r.Suffix, orig, false)
+	if r.Extension == "." {
+		ext = ""
+	} else if r.Extension != "" {
+		ext = "." + strings.TrimPrefix(r.Extension, ".")
+	}
+	return stem + ext
+}
+
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// PreviewBulkRename returns the new names of files by rule. Nothing is renamed.
+func PreviewBulkRename(v Volume, files []string, rule *RenameRule) ([]*RenamePreview, error) {
+	var find *regexp.Regexp
+	if rule.Find != "" {
+		var err error
+		if find, err = regexp.Compile(rule.Find); err != nil {
+			return nil, err
+		}
+	}
+	step := rule.SeqStep
+	if step == 0 {
+		step = 1
+	}
+	previews := make([]*RenamePreview, len(files))
+	sources := map[string]bool{}
+	targets := map[string]int{}
+	for i, name := range files {
+		name = path.Clean(name)
+		p := &RenamePreview{Path: name, NewName: path.Base(name)}
+		previews[i] = p
+		info, err := v.Stat(name)
+		if err != nil {
+			p.Conflict = err.Error()
+			continue
+		}
+		if sources[name] {
+			p.Conflict = "selected twice"
+			continue
+		}
+		sources[name] = true
+		c := &renameContext{v: v, name: name, seq: rule.SeqStart + i*step, pad: rule.SeqPad, info: info}
+		p.NewName = rule.newName(c, find)
+		if !validFileName(p.NewName) {
+			p.Conflict = "invalid name"
+		}
+	}
+	for i, p := range previews {
+		if p.Conflict != "" {
+			continue
+		}
+		// Names are compared case-insensitively, for case-insensitive file systems.
+		key := strings.ToLower(p.newPath())
+		if j, ok := targets[key]; ok {
+			p.Conflict = "same name as " + previews[j].Path
+			continue
+		}
+		targets[key] = i
+		// Existing files can be replaced only if they are renamed too.
+		if _, err := v.Stat(p.newPath()); err == nil && !sources[p.newPath()] && !strings.EqualFold(p.newPath(), p.Path) {
+			p.Conflict = "already exists"
+		}
+	}
+	// A file renamed to the name of another file conflicts if the other file is not renamed.
+	for changed := true; changed; {
+		changed = false
+		for _, p := range previews {
+			if p.Conflict != "" || p.NewName == path.Base(p.Path) {
+				continue
+			}
+			for _, q := range previews {
+				if q != p && q.Path == p.newPath() && (q.Conflict != "" || q.NewName == path.Base(q.Path)) {
+					p.Conflict = "already exists"
+					changed = true
+				}
+			}
+		}
+	}
+	return previews, nil
+}
+
+// BulkRename renames files by rule. If any file can not be renamed, nothing is renamed.
+func BulkRename(v Volume, files []string, rule *RenameRule) ([]*RenamePreview, error) {
+	previews, err := PreviewBulkRename(v, files, rule)
+	if err != nil {
+		return nil, err
+	}
+	var from, to []string
+	for _, p := range previews {
+		if p.Conflict != "" {
+			return previews, &fs.PathError{Op: "rename", Path: p.Path, Err: ErrRenameConflict}
+		}
+		if p.NewName != path.Base(p.Path) {
+			from = append(from, p.Path)
+			to = append(to, p.newPath())
+		}
+	}
+	return previews, renameAll(v, from, to)
+}
+
+// renameAll renames from[i] to to[i] as a whole. If a rename fails, the files already renamed are renamed back.
+// Files whose names are taken by other files (e.g. "a" <-> "b") are renamed to temporary names first.
+func renameAll(v Volume, from, to []string) error {
+	targets := map[string]bool{}
+	for _, p := range to {
+		targets[p] = true
+	}
+
+	type step struct{ from, to string }
+	var done []step
+	rename := func(from, to string) error {
+		if _, err := v.Stat(to); err == nil {
+			return &fs.PathError{Op: "rename", Path: to, Err: fs.ErrExist}
+		}
+		if err := v.Rename(from, to); err != nil {
+			return err
+		}
+		done = append(done, step{from, to})
+		return nil
+	}
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			v.Rename(done[i].to, done[i].from)
+		}
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	current := make([]string, len(from))
+	for i, p := range from {
+		current[i] = p
+		if targets[p] || strings.EqualFold(to[i], p) {
+			tmp := path.Join(path.Dir(p), fmt.Sprintf(".%s.renaming-%s-%d", path.Base(p), hex.EncodeToString(b), i))
+			if err := rename(p, tmp); err != nil {
+				rollback()
+				return err
+			}
+			current[i] = tmp
+		}
+	}
+	for i := range from {
+		if err := rename(current[i], to[i]); err != nil {
+			rollback()
+			return err
+		}
+	}
+	return nil
+}
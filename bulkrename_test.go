@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestBulkRenameUndo(t *testing.T) {
+	v := NewMemFS()
+	for _, name := range []string{"1.txt", "2.txt"} {
+		w, err := v.OpenWriter(name, os.O_WRONLY|os.O_CREATE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, name)
+		w.Close()
+	}
+	expect := func(op string, files map[string]string) {
+		t.Helper()
+		for name, want := range files {
+			if b, err := fs.ReadFile(v, name); err != nil || string(b) != want {
+				t.Errorf("%s: %s contains %q (%v), want %q", op, name, b, err, want)
+			}
+		}
+	}
+
+	// 1.txt -> 2.txt and 2.txt -> 3.txt
+	files := []string{"1.txt", "2.txt"}
+	previews, err := BulkRename(v, files, &RenameRule{Find: ".*", Replace: "{n}", SeqStart: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("BulkRename", map[string]string{"2.txt": "1.txt", "3.txt": "2.txt"})
+
+	h := NewHistory()
+	var items []*OperationItem
+	for _, p := range previews {
+		items = append(items, NewOperationItem(v, p.Path, p.newPath()))
+	}
+	h.Add("bulkrename", items...)
+	if _, err := h.Undo(v); err != nil {
+		t.Fatal("Undo:", err)
+	}
+	expect("Undo", map[string]string{"1.txt": "1.txt", "2.txt": "2.txt"})
+	if _, err := h.Redo(v); err != nil {
+		t.Fatal("Redo:", err)
+	}
+	expect("Redo", map[string]string{"2.txt": "1.txt", "3.txt": "2.txt"})
+}
+
+func TestBulkRenameDollar(t *testing.T) {
+	v := NewMemFS()
+	w, err := v.OpenWriter("a$1b.txt", os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	previews, err := PreviewBulkRename(v, []string{"a$1b.txt"}, &RenameRule{Find: "^(.*)$", Replace: "{name}-$1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a$1b-a$1b.txt"; previews[0].NewName != want {
+		t.Errorf("new name %q, want %q", previews[0].NewName, want)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var ErrNotLocalFile = errors.New("not a local file")
+
+// DesktopApp is an application found in the XDG desktop entries.
+type DesktopApp struct {
+	ID        string `json:"id"` // e.g. "org.gnome.eog.desktop"
+	Name      string `json:"name"`
+	Icon      string `json:"icon,omitempty"`
+	exec      string
+	mimeTypes []string
+	hidden    bool
+}
+
+func xdgDataDirs() []string {
+	dirs := []string{xdgDataHome()}
+	env := os.Getenv("XDG_DATA_DIRS")
+	if env == "" {
+		env = "/usr/local/share:/usr/share"
+	}
+	return append(dirs, filepath.SplitList(env)...)
+}
+
+func xdgConfigDirs() []string {
+	dirs := []string{}
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	env := os.Getenv("XDG_CONFIG_DIRS")
+	if env == "" {
+		env = "/etc/xdg"
+	}
+	return append(dirs, filepath.SplitList(env)...)
+}
+
+// LoadDesktopApps reads the desktop entries in the applications directories of XDG data dirs.
+// Entries in earlier directories override the same IDs in later ones.
+func LoadDesktopApps() map[string]*DesktopApp {
+	apps := map[string]*DesktopApp{}
+	for _, dir := range xdgDataDirs() {
+		root := filepath.Join(dir, "applications")
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, ".desktop") {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, p)
+			id := strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+			if _, ok := apps[id]; ok {
+				return nil
+			}
+			if app, err := parseDesktopEntry(p); err == nil {
+				app.ID = id
+				apps[id] = app
+			}
+			return nil
+		})
+	}
+	return apps
+}
+
+func parseDesktopEntry(file string) (*DesktopApp, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	app := &DesktopApp{}
+	section := ""
+	typ := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "[Desktop Entry]" {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Type":
+			typ = strings.TrimSpace(value)
+		case "Name":
+			app.Name = strings.TrimSpace(value)
+		case "Icon":
+			app.Icon = strings.TrimSpace(value)
+		case "Exec":
+			app.exec = strings.TrimSpace(value)
+		case "MimeType":
+			app.mimeTypes = strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' })
+		case "NoDisplay", "Hidden":
+			app.hidden = app.hidden || strings.TrimSpace(value) == "true"
+		case "TryExec":
+			if _, err := exec.LookPath(strings.TrimSpace(value)); err != nil {
+				app.hidden = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if typ != "Application" || app.exec == "" {
+		return nil, errors.New("not an application")
+	}
+	return app, nil
+}
+
+func (app *DesktopApp) supports(mimeType string) bool {
+	major, _, _ := strings.Cut(mimeType, "/")
+	for _, t := range app.mimeTypes {
+		if t == mimeType || t == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultDesktopApps returns the IDs of the default and added applications of mimeType in mimeapps.list.
+func defaultDesktopApps(mimeType string) []string {
+	ids := []string{}
+	for _, dir := range append(xdgConfigDirs(), filepath.Join(xdgDataHome(), "applications")) {
+		f, err := os.Open(filepath.Join(dir, "mimeapps.list"))
+		if err != nil {
+			continue
+		}
+		section := ""
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") {
+				section = line
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if ok && strings.TrimSpace(key) == mimeType && (section == "[Default Applications]" || section == "[Added Associations]") {
+				ids = append(ids, strings.FieldsFunc(value, func(r rune) bool { return r == ';' })...)
+			}
+		}
+		f.Close()
+	}
+	return ids
+}
+
+// DesktopAppsForType returns the applications which can open files of mimeType.
+// Applications in mimeapps.list and the association of GetFileAssociation come first.
+func DesktopAppsForType(mimeType string) []*DesktopApp {
+	mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	all := LoadDesktopApps()
+	result := []*DesktopApp{}
+	added := map[string]bool{}
+	add := func(app *DesktopApp) {
+		if app != nil && !added[app.ID] {
+			added[app.ID] = true
+			result = append(result, app)
+		}
+	}
+	for _, id := range append(defaultDesktopApps(mimeType), GetFileAssociation(mimeType).OpenWith...) {
+		add(all[id])
+	}
+	rest := []*DesktopApp{}
+	for _, app := range all {
+		if !app.hidden && app.supports(mimeType) {
+			rest = append(rest, app)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Name < rest[j].Name })
+	for _, app := range rest {
+		add(app)
+	}
+	return result
+}
+
+// desktopExecArgs expands the field codes of the Exec key for file.
+func desktopExecArgs(app *DesktopApp, file string) []string {
+	args := []string{}
+	hasFile := false
+	for _, arg := range splitDesktopExec(app.exec) {
+		switch arg {
+		case "%f", "%F", "%u", "%U":
+			args = append(args, file)
+			hasFile = true
+			continue
+		case "%i":
+			if app.Icon != "" {
+				args = append(args, "--icon", app.Icon)
+			}
+			continue
+		}
+		var b strings.Builder
+		for i := 0; i < len(arg); i++ {
+			if arg[i] != '%' || i+1 >= len(arg) {
+				b.WriteByte(arg[i])
+				continue
+			}
+			i++
+			switch arg[i] {
+			case '%':
+				b.WriteByte('%')
+			case 'c':
+				b.WriteString(app.Name)
+			case 'f', 'u':
+				b.WriteString(file)
+				hasFile = true
+			}
+		}
+		if b.Len() > 0 {
+			args = append(args, b.String())
+		}
+	}
+	if !hasFile {
+		args = append(args, file)
+	}
+	return args
+}
+
+// splitDesktopExec splits the Exec value into arguments. Arguments can be quoted with double quotes.
+func splitDesktopExec(s string) []string {
+	args := []string{}
+	var b strings.Builder
+	quoted, inArg := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args
+}
+
+func startDetached(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// OpenExternal opens file with the default application of the desktop.
+func OpenExternal(file string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return startDetached("rundll32", "url.dll,FileProtocolHandler", file)
+	case "darwin":
+		return startDetached("open", file)
+	}
+	if _, err := exec.LookPath("xdg-open"); err == nil {
+		return startDetached("xdg-open", file)
+	}
+	return startDetached("gio", "open", file)
+}
+
+// OpenWith opens file with the desktop entry appID.
+func OpenWith(file, appID string) error {
+	app := LoadDesktopApps()[appID]
+	if app == nil {
+		return errors.New("application not found: " + appID)
+	}
+	args := desktopExecArgs(app, file)
+	return startDetached(args[0], args[1:]...)
+}
+
+// RevealInFolder shows file selected in the file manager of the desktop.
+func RevealInFolder(file string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return startDetached("explorer", "/select,"+file)
+	case "darwin":
+		return startDetached("open", "-R", file)
+	}
+	// dbus-send separates array items by commas.
+	uri := strings.ReplaceAll((&url.URL{Scheme: "file", Path: file}).String(), ",", "%2C")
+	err := exec.Command("dbus-send", "--session", "--print-reply", "--dest=org.freedesktop.FileManager1",
+		"/org/freedesktop/FileManager1", "org.freedesktop.FileManager1.ShowItems", "array:string:"+uri, "string:").Run()
+	if err == nil {
+		return nil
+	}
+	return OpenExternal(filepath.Dir(file))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+	exifMaxHeaderSize       = 256 * 1024
+)
+
+var errNoExif = errors.New("exif not found")
+
+// ReadDateTaken returns DateTimeOriginal (or DateTime) in the EXIF of a JPEG or TIFF based file in the local time zone.
+func ReadDateTaken(r io.Reader) (time.Time, error) {
+	b, err := io.ReadAll(io.LimitReader(r, exifMaxHeaderSize))
+	if err != nil {
+		return time.Time{}, err
+	}
+	tiff, err := findExif(b)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseExifDate(tiff)
+}
+
+// findExif returns the TIFF structure in the APP1 segment of JPEG, or b itself if it is a TIFF file.
+func findExif(b []byte) ([]byte, error) {
+	if bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*")) {
+		return b, nil
+	}
+	if !bytes.HasPrefix(b, []byte{0xff, 0xd8}) {
+		return nil, errNoExif
+	}
+	for p := 2; p+4 <= len(b) && b[p] == 0xff; {
+		marker := b[p+1]
+		size := int(binary.BigEndian.Uint16(b[p+2:]))
+		if marker == 0xda || size < 2 || p+2+size > len(b) {
+			break
+		}
+		seg := b[p+4 : p+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:], nil
+		}
+		p += 2 + size
+	}
+	return nil, errNoExif
+}
+
+func parseExifDate(tiff []byte) (time.Time, error) {
+	if len(tiff) < 8 {
+		return time.Time{}, errNoExif
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if tiff[0] == 'M' {
+		order = binary.BigEndian
+	}
+	// readIFD returns the offsets of the values of the tags in the IFD at offset.
+	readIFD := func(offset uint32) map[uint16]uint32 {
+		tags := map[uint16]uint32{}
+		if int(offset)+2 > len(tiff) {
+			return tags
+		}
+		n := int(order.Uint16(tiff[offset:]))
+		for i := 0; i < n; i++ {
+			e := int(offset) + 2 + i*12
+			if e+12 > len(tiff) {
+				break
+			}
+			tag, count := order.Uint16(tiff[e:]), order.Uint32(tiff[e+4:])
+			if count <= 4 {
+				tags[tag] = uint32(e + 8)
+			} else {
+				tags[tag] = order.Uint32(tiff[e+8:])
+			}
+		}
+		return tags
+	}
+	ifd0 := readIFD(order.Uint32(tiff[4:]))
+	var tags []uint32
+	if p, ok := ifd0[exifTagExifIFD]; ok && int(p)+4 <= len(tiff) {
+		if v, ok := readIFD(order.Uint32(tiff[p:]))[exifTagDateTimeOriginal]; ok {
+			tags = append(tags, v)
+		}
+	}
+	if v, ok := ifd0[exifTagDateTime]; ok {
+		tags = append(tags, v)
+	}
+	for _, v := range tags {
+		// "2006:01:02 15:04:05\0"
+		if int(v)+19 > len(tiff) {
+			continue
+		}
+		if t, err := time.ParseInLocation("2006:01:02 15:04:05", string(tiff[v:v+19]), time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errNoExif
+}
@@ -16,6 +16,13 @@ function mkEl(tag, children, attrs) {
 
 let eachElements = (q, f) => document.querySelectorAll(q).forEach(f);
 
+// Same as IsTextMimeType in mimetype.go.
+const textMimeTypes = ['application/json', 'application/yaml', 'application/toml', 'application/xml', 'application/javascript'];
+let isTextType = (type) => {
+	let t = type.split(';')[0].trim();
+	return t.startsWith('text/') || textMimeTypes.includes(t);
+};
+
 function formatTime(t) {
 	return '' + (t / 60 | 0) + ':' + (t % 60 | 0).toString().padStart(2, '0');
 }
@@ -85,6 +92,7 @@ class MediaPlayer {
 		this.loop = false;
 		this.playbackRate = 1.0;
 		this.onEnded = null;
+		this.hlsItem = null;
 		/** @type {HTMLElement} */
 		this.contentEl = el.querySelector('.media-player-content');
 		window.addEventListener('popstate', ev => !this.el.classList.contains('small') && this.hide());
@@ -111,7 +119,7 @@ class MediaPlayer {
 		let type = item.type.split('/', 2)[0];
 		if (type == 'image') {
 			this.el.classList.remove('playable');
-			let content = mkEl('img', [], { src: item.url || item.path || '' });
+			let content = mkEl('img', [], { src: item.viewUrl || item.url || item.path || '' });
 			if (item.url == null && item.fetch) {
 				(async () => {
 					let url = URL.createObjectURL(await (await item.fetch()).blob());
@@ -139,6 +147,8 @@ class MediaPlayer {
 					}
 				};
 				new MP4Player(content).setBufferedReader(new BufferedReader(options));
+			} else if (item.hlsUrl && !content.canPlayType(item.type) && this._playHls(content, item)) {
+				// transcoded by the server
 			} else {
 				content.src = item.url || item.path;
 			}
@@ -146,6 +156,9 @@ class MediaPlayer {
 				this.el.classList.remove('loading');
 			});
 			content.addEventListener('error', (ev) => {
+				if (item.hlsUrl && this.hlsItem != item && this._playHls(content, item)) {
+					return;
+				}
 				this.el.classList.remove('loading');
 			});
 			content.addEventListener('ended', (ev) => {
@@ -156,6 +169,16 @@ class MediaPlayer {
 			parent.append(content);
 		}
 	}
+	_playHls(content, item) {
+		// Only browsers which play HLS natively are supported.
+		if (!content.canPlayType('application/vnd.apple.mpegurl')) {
+			return false;
+		}
+		content.src = item.hlsUrl;
+		this.hlsItem = item;
+		content.play();
+		return true;
+	}
 	playPause() {
 		if (!this.mediaEl) return;
 		if (!this.mediaEl.paused) {
@@ -178,6 +201,10 @@ class MediaPlayer {
 		}
 	}
 	_clearMediaEl() {
+		if (this.hlsItem) {
+			fetch(this.hlsItem.hlsUrl + '&stop=1');
+			this.hlsItem = null;
+		}
 		if (this.mediaEl && this.mediaEl.src) {
 			this.mediaEl.src = '';
 		}
@@ -624,9 +651,22 @@ class ContentInfoView {
 		this.contentEl.append(
 			mkEl('div', 'Size: ' + formatSize(sizeSum), { title: sizeSum, className: 'fileattr' })
 		);
+		let paths = Array.from(items, item => item.finfo?.path);
+		if (paths.every(p => p)) {
+			this.contentEl.append(mkEl('div', mkEl('a', 'Download zip', { href: zipUrl(paths), target: '_blank' }), { className: 'fileattr' }));
+		}
 	}
 }
 
+function zipUrl(paths) {
+	let dir = paths[0].includes('/') ? paths[0].substring(0, paths[0].lastIndexOf('/')) : '.';
+	let url = "volume?download=" + encodeURIComponent(dir) + "&mode=zip";
+	for (let p of paths) {
+		url += "&path=" + encodeURIComponent(p.substring(p.lastIndexOf('/') + 1));
+	}
+	return url;
+}
+
 let mediaPlayerController = new MediaPlayerController();
 
 function openItem(item, cursor = null, action = null) {
@@ -755,14 +795,13 @@ class FileListView {
 			let action = JSON.parse(lines[0])
 			ev.preventDefault();
 			let folder = this._getCurrentFolder();
-			if (folder == null || !folder.caps?.includes('transfer') || folder.path == action.srcPath) {
+			if (folder == null || !folder.caps?.includes('create') || folder.path == action.srcPath) {
 				alert('Cannot copy files');
 				return;
 			}
 			let transferMode = action.mode == 'cut' ? 'move' : 'copy';
-			for (let path of lines.slice(1)) {
-				await folder.transferFiles([path], transferMode);
-			}
+			await folder.transferFiles(lines.slice(1), transferMode);
+			this._refreshItems();
 		});
 
 		let localConfig = {};
@@ -783,6 +822,12 @@ class FileListView {
 			document.getElementById('item-list-mode-button').textContent = localConfig.listMode ? 'view_module' : 'view_list';
 			localStorage.setItem('localConfig', JSON.stringify(localConfig));
 		});
+		document.getElementById('item-anim-mode-button').addEventListener('click', (ev) => {
+			localConfig.animatedThumbnail = !localConfig.animatedThumbnail;
+			localStorage.setItem('localConfig', JSON.stringify(localConfig));
+			this._refreshItems();
+		});
+		this.localConfig = localConfig;
 
 		let savedScrollTop = 0;
 		this.scrollTop = 0;
@@ -795,7 +840,7 @@ class FileListView {
 			}
 		}, false);
 		this.el.addEventListener('dragover', ev => {
-			if (this.getCurrentFolder()?.caps?.includes('writeFile')) {
+			if (this.getCurrentFolder()?.caps?.includes('create')) {
 				ev.preventDefault();
 			}
 		});
@@ -881,16 +926,45 @@ class FileListView {
 		this.checkScroll();
 	}
 
-	_confirmAndRemoveAll(itemEls) {
-		if (itemEls.length > 0 && confirm(itemEls.length > 1 ? `Remove ${itemEls.length} files?` : `Remove ${itemEls[0].finfo.name}?`)) {
+	_confirmAndRemoveAll(itemEls, permanent = false) {
+		let verb = this._getCurrentFolder()?.caps?.includes('trash') && !permanent ? 'Move to trash' : 'Remove permanently';
+		if (itemEls.length > 0 && confirm(itemEls.length > 1 ? `${verb} ${itemEls.length} files?` : `${verb} ${itemEls[0].finfo.name}?`)) {
 			this.currentItemEl = itemEls[itemEls.length - 1].nextElementSibling || itemEls[0].previousElementSibling;
 			for (let itemEl of itemEls) {
-				itemEl.finfo.remove();
+				itemEl.finfo.remove(permanent);
 				itemEl.parentNode.removeChild(itemEl);
 			}
 		}
 	}
 
+	async _bulkRename(paths) {
+		let find = prompt('Find (regular expression)', '(.*)');
+		if (find == null) {
+			return;
+		}
+		let replace = prompt('Replace with ($1: group, {n}: number, {date} {time}: date taken, {name}: original name)', '$1');
+		if (replace == null) {
+			return;
+		}
+		let rule = { find, replace, seqStart: 1, seqPad: String(paths.length).length };
+		let previews = await window.go.main.App.PreviewBulkRename(paths, rule);
+		if (!previews) {
+			alert('Invalid rule');
+			return;
+		}
+		let list = previews.map(p => `${p.path.split('/').pop()} -> ${p.newName}` + (p.conflict ? ` (${p.conflict})` : '')).join('\n');
+		if (previews.some(p => p.conflict)) {
+			alert('Cannot rename:\n' + list);
+			return;
+		}
+		if (confirm('Rename?\n' + list)) {
+			if (!await window.go.main.App.BulkRename(paths, rule)) {
+				alert('Failed to rename');
+			}
+			this._refreshItems();
+		}
+	}
+
 	_createItemEl(f, prefix) {
 		let iconEl = mkEl('img', [], { 'className': 'thumbnail' });
 		let isList = f.type == 'folder' || f.type == 'list';
@@ -901,7 +975,7 @@ class FileListView {
 				el.src = url;
 			});
 		} else {
-			let turl = f.thumbnailUrl || (isList ? 'images/icon_folder.svg' : 'images/icon_file.svg');
+			let turl = (this.localConfig.animatedThumbnail && f.animatedThumbnailUrl) || f.thumbnailUrl || (isList ? 'images/icon_folder.svg' : 'images/icon_file.svg');
 			this.imageLoadQueue.add(iconEl, el => el.src = turl);
 		}
 
@@ -979,16 +1053,39 @@ class FileListView {
 			let url = '#list:' + encodeURIComponent(f.path).replace('%2F', '/');
 			menuEl.append(mkEl('li', mkEl('a', 'Browse', { 'href': url, 'title': 'Browse' })));
 			menuEl.append(mkEl('li', mkEl('button', 'Play Files', { onclick: play })));
+			if (f.type == 'folder' && f.path) {
+				menuEl.append(mkEl('li', mkEl('a', 'Download zip', { 'target': '_blank', 'href': zipUrl([f.path]), 'title': 'Download zip' })));
+			}
 		}
 		if (!isList && !f.type.startsWith('link/')) {
 			let dlEl = mkEl('a', 'Download', { 'target': '_blank', 'href': f.url, download: f.name, 'title': 'Download' });
 			if (!f.url && f.fetch) { dlEl.onclick = downloadBlob; }
 			menuEl.append(mkEl('li', dlEl));
 		}
+		if (window.runtime && f.path) {
+			// Desktop applications are available only in the app window.
+			menuEl.append(mkEl('li', mkEl('button', 'Open Externally', { onclick: () => window.go.main.App.OpenExternal(f.path) })));
+			menuEl.append(mkEl('li', mkEl('button', 'Show in Folder', { onclick: () => window.go.main.App.RevealInFolder(f.path) })));
+		}
+		if (f.type.startsWith('text/html') && f.path) {
+			menuEl.append(mkEl('li', mkEl('button', 'Preview', {
+				onclick: async () => {
+					let url = await window.go.main.App.PreviewURL(f.path);
+					if (url) {
+						window.runtime ? window.runtime.BrowserOpenURL(url) : window.open(url, '_blank', 'noopener');
+					}
+				}
+			})));
+		}
 		if (f.remove) {
 			menuEl.append(mkEl('li', mkEl('button', 'Delete', {
 				onclick: () => this._confirmAndRemoveAll([el])
 			})));
+			if (this._getCurrentFolder()?.caps?.includes('trash')) {
+				menuEl.append(mkEl('li', mkEl('button', 'Delete permanently', {
+					onclick: () => this._confirmAndRemoveAll([el], true)
+				})));
+			}
 		}
 		if (f.rename) {
 			menuEl.append(mkEl('li', mkEl('button', 'Rename', {
@@ -1039,6 +1136,17 @@ class FileListView {
 			this._refreshItems();
 			return true;
 		}
+		if ((ev.ctrlKey || ev.metaKey) && (ev.code == 'KeyZ' || ev.code == 'KeyY')) {
+			let redo = ev.code == 'KeyY' || ev.shiftKey;
+			(redo ? window.go.main.App.Redo() : window.go.main.App.Undo()).then(op => {
+				if (op) {
+					this._refreshItems();
+				} else {
+					alert(redo ? 'Cannot redo' : 'Cannot undo');
+				}
+			});
+			return true;
+		}
 		if (ev.altKey) {
 			if (ev.code == 'ArrowUp') {
 				let dirs = this.titleEl.querySelectorAll('a');
@@ -1051,11 +1159,11 @@ class FileListView {
 		} else if (ev.code == 'Delete' && this._getCurrentFolder()?.caps?.includes('remove')) {
 			let selected = this._getSelected();
 			if (selected.length > 0) {
-				this._confirmAndRemoveAll(selected);
+				this._confirmAndRemoveAll(selected, ev.shiftKey);
 			} else {
 				let el = this.listEl.querySelector(':scope > :focus-within');
 				if (el) {
-					this._confirmAndRemoveAll([el]);
+					this._confirmAndRemoveAll([el], ev.shiftKey);
 				}
 			}
 			return true;
@@ -1067,8 +1175,11 @@ class FileListView {
 			this._updateSelection();
 			return true;
 		} else if (ev.code == 'F2') {
+			let selected = Array.from(this._getSelected());
 			let f = this.listEl.querySelector(':scope > :focus-within')?.finfo;
-			if (f?.rename) {
+			if (selected.length > 1 && this._getCurrentFolder()?.caps?.includes('rename') && selected.every(el => el.finfo?.path)) {
+				this._bulkRename(selected.map(el => el.finfo.path));
+			} else if (f?.rename) {
 				let name = prompt('Rename', f.name);
 				if (name && name != f.name) {
 					f.rename(name).then(_ => this._refreshItems());
@@ -1154,26 +1265,90 @@ class FileListLoader {
 		this.caps = res.folder.caps || [];
 		console.log(this.caps);
 		let canRemove = this.caps.includes('remove')
+		let canTrash = this.caps.includes('trash')
 		for (let item of res.items) {
 			item.path = item.path || ((this.path ? this.path + "/" : '') + item.name)
 			item.url = "volume?download=" + encodeURIComponent(item.path);
-			if (item.type.startsWith('image/')) {
+			if (item.type.startsWith('image/') || isTextType(item.type)) {
 				item.thumbnailUrl = item.url + "&mode=thumbnail"
 			}
+			if (item.type.startsWith('video/')) {
+				item.hlsUrl = item.url + "&mode=hls";
+				item.thumbnailUrl = item.url + "&mode=thumbnail";
+			}
+			if (item.type.startsWith('video/') || item.type == 'image/gif') {
+				item.animatedThumbnailUrl = item.url + "&mode=animated_thumbnail";
+			}
+			if (item.type == 'image/tiff' || item.type == 'image/bmp') {
+				let size = Math.ceil(Math.max(screen.width, screen.height) * devicePixelRatio);
+				item.viewUrl = item.url + "&mode=convert&w=" + size + "&h=" + size;
+			}
 			if (canRemove) {
-				item.remove = () => window.go.main.App.Remove(item.path);
+				item.remove = (permanent) => canTrash && !permanent ? window.go.main.App.MoveToTrash(item.path) :
+					item.type == 'folder' ? this.removeAll(item.path) : window.go.main.App.Remove(item.path);
 			}
 		}
 		return res
 	}
+	async removeAll(path) {
+		let id = await window.go.main.App.RemoveAll(path);
+		if (!id) {
+			return false;
+		}
+		for (; ;) {
+			let report = await window.go.main.App.GetRemoveStatus(id);
+			if (!report) {
+				return false;
+			}
+			if (report.done) {
+				if (report.failures.length > 0) {
+					alert(`Failed to remove ${report.failures.length} items:\n` + report.failures.map(f => `${f.path}: ${f.error}`).join('\n'));
+				}
+				return report.failures.length == 0 && !report.canceled;
+			}
+			await new Promise(resolve => setTimeout(resolve, 500));
+		}
+	}
 	async transferFiles(files, mode = '', signal = null) {
 		await window.go.main.App.TransferFiles(this.path || ".", files, mode);
 	}
 	async mkdir(path) {
 		return await window.go.main.App.Remove(path);
 	}
+	async writeFile(name, blob) {
+		let url = "volume?upload=" + encodeURIComponent((this.path ? this.path + "/" : '') + name);
+		// Upload in chunks to resume after errors if the folder supports appending.
+		let chunkSize = this.caps.includes('append') ? uploadChunkSize : blob.size;
+		let offset = 0, retry = 0;
+		do {
+			let res;
+			try {
+				res = await fetch(url + "&offset=" + offset, {
+					method: 'PUT', headers: { 'Content-Type': 'application/octet-stream' },
+					body: blob.slice(offset, offset + chunkSize)
+				});
+			} catch (e) {
+				if (++retry > uploadMaxRetry || chunkSize == blob.size) { throw e; }
+				await new Promise(resolve => setTimeout(resolve, 1000 * retry));
+				// Continue from the data written before the error.
+				let head = await fetch(url, { method: 'HEAD' }).catch(() => null);
+				if (head?.ok) {
+					offset = +head.headers.get('Upload-Offset');
+				}
+				continue;
+			}
+			if (!res.ok && res.status != 409) {
+				throw new Error(await res.text());
+			}
+			offset = +res.headers.get('Upload-Offset');
+			retry = 0;
+		} while (offset < blob.size);
+	}
 }
 
+const uploadChunkSize = 8 * 1024 * 1024;
+const uploadMaxRetry = 5;
+
 function search(text, targets) {
 	let normalize = function (s) {
 		return s.replace(/[\s　]+/, '').replace(/[－?―]/g, '-').replace(/[Ａ-Ｚａ-ｚ０-９]/g, function (s) {
@@ -2,12 +2,74 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function BulkRename(arg1:Array<string>,arg2:main.RenameRule):Promise<boolean>;
+
+export function CancelRemove(arg1:string):Promise<boolean>;
+
+export function ConvertImage(arg1:string,arg2:string,arg3:number,arg4:number,arg5:string):Promise<boolean>;
+
+export function CreateShare(arg1:string,arg2:number,arg3:number,arg4:boolean):Promise<main.Share>;
+
+export function EmptyTrash():Promise<boolean>;
+
+export function GetApplications(arg1:string):Promise<Array<main.DesktopApp>>;
+
+export function GetFileAssociation(arg1:string):Promise<main.FileAssociation>;
+
 export function GetFiles(arg1:string,arg2:number,arg3:number):Promise<main.FileList>;
 
+export function GetMimeType(arg1:string):Promise<string>;
+
+export function GetOperations():Promise<Array<main.Operation>>;
+
+export function GetRemoveStatus(arg1:string):Promise<main.RemoveReport>;
+
+export function GetShares():Promise<Array<main.Share>>;
+
 export function Greet(arg1:string):Promise<string>;
 
+export function ListTrash():Promise<Array<main.TrashItem>>;
+
 export function Mkdir(arg1:string):Promise<boolean>;
 
+export function MountHTTPIndex(arg1:string,arg2:main.HTTPIndexConfig):Promise<boolean>;
+
+export function MountS3(arg1:string,arg2:main.S3Config):Promise<boolean>;
+
+export function MountSFTP(arg1:string,arg2:main.SFTPConfig):Promise<boolean>;
+
+export function MountWebDAV(arg1:string,arg2:main.WebDAVConfig):Promise<boolean>;
+
+export function MoveToTrash(arg1:string):Promise<boolean>;
+
+export function OpenExternal(arg1:string):Promise<boolean>;
+
+export function OpenWith(arg1:string,arg2:string):Promise<boolean>;
+
+export function PreviewBulkRename(arg1:Array<string>,arg2:main.RenameRule):Promise<Array<main.RenamePreview>>;
+
+export function PreviewURL(arg1:string):Promise<string>;
+
+export function Redo():Promise<main.Operation>;
+
 export function Remove(arg1:string):Promise<boolean>;
 
+export function RemoveAll(arg1:string):Promise<string>;
+
 export function Rename(arg1:string,arg2:string):Promise<boolean>;
+
+export function RestoreFromTrash(arg1:string):Promise<boolean>;
+
+export function RevealInFolder(arg1:string):Promise<boolean>;
+
+export function RevokeShare(arg1:string):Promise<boolean>;
+
+export function StartWebDAVServer(arg1:string,arg2:boolean):Promise<boolean>;
+
+export function StopWebDAVServer():Promise<boolean>;
+
+export function TransferFiles(arg1:string,arg2:Array<string>,arg3:string):Promise<boolean>;
+
+export function Undo():Promise<main.Operation>;
+
+export function Unmount(arg1:string):Promise<boolean>;
@@ -2,22 +2,146 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function BulkRename(arg1, arg2) {
+  return window['go']['main']['App']['BulkRename'](arg1, arg2);
+}
+
+export function CancelRemove(arg1) {
+  return window['go']['main']['App']['CancelRemove'](arg1);
+}
+
+export function ConvertImage(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['ConvertImage'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function CreateShare(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['CreateShare'](arg1, arg2, arg3, arg4);
+}
+
+export function EmptyTrash() {
+  return window['go']['main']['App']['EmptyTrash']();
+}
+
+export function GetApplications(arg1) {
+  return window['go']['main']['App']['GetApplications'](arg1);
+}
+
+export function GetFileAssociation(arg1) {
+  return window['go']['main']['App']['GetFileAssociation'](arg1);
+}
+
 export function GetFiles(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetFiles'](arg1, arg2, arg3);
 }
 
+export function GetMimeType(arg1) {
+  return window['go']['main']['App']['GetMimeType'](arg1);
+}
+
+export function GetOperations() {
+  return window['go']['main']['App']['GetOperations']();
+}
+
+export function GetRemoveStatus(arg1) {
+  return window['go']['main']['App']['GetRemoveStatus'](arg1);
+}
+
+export function GetShares() {
+  return window['go']['main']['App']['GetShares']();
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ListTrash() {
+  return window['go']['main']['App']['ListTrash']();
+}
+
 export function Mkdir(arg1) {
   return window['go']['main']['App']['Mkdir'](arg1);
 }
 
+export function MountHTTPIndex(arg1, arg2) {
+  return window['go']['main']['App']['MountHTTPIndex'](arg1, arg2);
+}
+
+export function MountS3(arg1, arg2) {
+  return window['go']['main']['App']['MountS3'](arg1, arg2);
+}
+
+export function MountSFTP(arg1, arg2) {
+  return window['go']['main']['App']['MountSFTP'](arg1, arg2);
+}
+
+export function MountWebDAV(arg1, arg2) {
+  return window['go']['main']['App']['MountWebDAV'](arg1, arg2);
+}
+
+export function MoveToTrash(arg1) {
+  return window['go']['main']['App']['MoveToTrash'](arg1);
+}
+
+export function OpenExternal(arg1) {
+  return window['go']['main']['App']['OpenExternal'](arg1);
+}
+
+export function OpenWith(arg1, arg2) {
+  return window['go']['main']['App']['OpenWith'](arg1, arg2);
+}
+
+export function PreviewBulkRename(arg1, arg2) {
+  return window['go']['main']['App']['PreviewBulkRename'](arg1, arg2);
+}
+
+export function PreviewURL(arg1) {
+  return window['go']['main']['App']['PreviewURL'](arg1);
+}
+
+export function Redo() {
+  return window['go']['main']['App']['Redo']();
+}
+
 export function Remove(arg1) {
   return window['go']['main']['App']['Remove'](arg1);
 }
 
+export function RemoveAll(arg1) {
+  return window['go']['main']['App']['RemoveAll'](arg1);
+}
+
 export function Rename(arg1, arg2) {
   return window['go']['main']['App']['Rename'](arg1, arg2);
 }
+
+export function RestoreFromTrash(arg1) {
+  return window['go']['main']['App']['RestoreFromTrash'](arg1);
+}
+
+export function RevealInFolder(arg1) {
+  return window['go']['main']['App']['RevealInFolder'](arg1);
+}
+
+export function RevokeShare(arg1) {
+  return window['go']['main']['App']['RevokeShare'](arg1);
+}
+
+export function StartWebDAVServer(arg1, arg2) {
+  return window['go']['main']['App']['StartWebDAVServer'](arg1, arg2);
+}
+
+export function StopWebDAVServer() {
+  return window['go']['main']['App']['StopWebDAVServer']();
+}
+
+export function TransferFiles(arg1, arg2, arg3) {
+  return window['go']['main']['App']['TransferFiles'](arg1, arg2, arg3);
+}
+
+export function Undo() {
+  return window['go']['main']['App']['Undo']();
+}
+
+export function Unmount(arg1) {
+  return window['go']['main']['App']['Unmount'](arg1);
+}
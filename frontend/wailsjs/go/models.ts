@@ -90,6 +90,326 @@ export namespace main {
 		    return a;
 		}
 	}
+	export class SFTPConfig {
+	    host: string;
+	    user: string;
+	    password?: string;
+	    keyFile?: string;
+	    keyPassphrase?: string;
+	    useAgent?: boolean;
+	    knownHostsFile?: string;
+	    hostKeyFingerprint?: string;
+	    root?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SFTPConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.host = source["host"];
+	        this.user = source["user"];
+	        this.password = source["password"];
+	        this.keyFile = source["keyFile"];
+	        this.keyPassphrase = source["keyPassphrase"];
+	        this.useAgent = source["useAgent"];
+	        this.knownHostsFile = source["knownHostsFile"];
+	        this.hostKeyFingerprint = source["hostKeyFingerprint"];
+	        this.root = source["root"];
+	    }
+	}
+	export class WebDAVConfig {
+	    url: string;
+	    user?: string;
+	    password?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new WebDAVConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.user = source["user"];
+	        this.password = source["password"];
+	    }
+	}
+	export class S3Config {
+	    endpoint?: string;
+	    region?: string;
+	    bucket: string;
+	    accessKey?: string;
+	    secretKey?: string;
+	    prefix?: string;
+	    pathStyle?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new S3Config(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.endpoint = source["endpoint"];
+	        this.region = source["region"];
+	        this.bucket = source["bucket"];
+	        this.accessKey = source["accessKey"];
+	        this.secretKey = source["secretKey"];
+	        this.prefix = source["prefix"];
+	        this.pathStyle = source["pathStyle"];
+	    }
+	}
+	export class HTTPIndexConfig {
+	    url: string;
+	    user?: string;
+	    password?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HTTPIndexConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.user = source["user"];
+	        this.password = source["password"];
+	    }
+	}
+	export class Share {
+	    id: string;
+	    token: string;
+	    path: string;
+	    created: number;
+	    expires: number;
+	    maxDownloads: number;
+	    downloads: number;
+	    allowList: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Share(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.token = source["token"];
+	        this.path = source["path"];
+	        this.created = source["created"];
+	        this.expires = source["expires"];
+	        this.maxDownloads = source["maxDownloads"];
+	        this.downloads = source["downloads"];
+	        this.allowList = source["allowList"];
+	    }
+	}
+	export class FileAssociation {
+	    type: string;
+	    previewer: string;
+	    openWith: string[];
+	    external: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileAssociation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.previewer = source["previewer"];
+	        this.openWith = source["openWith"];
+	        this.external = source["external"];
+	    }
+	}
+	export class DesktopApp {
+	    id: string;
+	    name: string;
+	    icon?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DesktopApp(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.icon = source["icon"];
+	    }
+	}
+	export class TrashItem {
+	    id: string;
+	    name: string;
+	    originalPath: string;
+	    deletionTime: number;
+	    size: number;
+	    isDir: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrashItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.originalPath = source["originalPath"];
+	        this.deletionTime = source["deletionTime"];
+	        this.size = source["size"];
+	        this.isDir = source["isDir"];
+	    }
+	}
+	export class RemoveFailure {
+	    path: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RemoveFailure(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.error = source["error"];
+	    }
+	}
+	export class RemoveReport {
+	    path: string;
+	    totalFiles: number;
+	    totalBytes: number;
+	    files: number;
+	    bytes: number;
+	    failures: RemoveFailure[];
+	    done: boolean;
+	    canceled: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RemoveReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.totalFiles = source["totalFiles"];
+	        this.totalBytes = source["totalBytes"];
+	        this.files = source["files"];
+	        this.bytes = source["bytes"];
+	        this.failures = this.convertValues(source["failures"], RemoveFailure);
+	        this.done = source["done"];
+	        this.canceled = source["canceled"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OperationItem {
+	    src?: string;
+	    dst?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new OperationItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.src = source["src"];
+	        this.dst = source["dst"];
+	    }
+	}
+	export class Operation {
+	    id: number;
+	    kind: string;
+	    items: OperationItem[];
+	    time: number;
+	    undone: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Operation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.kind = source["kind"];
+	        this.items = this.convertValues(source["items"], OperationItem);
+	        this.time = source["time"];
+	        this.undone = source["undone"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RenameRule {
+	    find?: string;
+	    replace?: string;
+	    case?: string;
+	    prefix?: string;
+	    suffix?: string;
+	    extension?: string;
+	    seqStart?: number;
+	    seqStep?: number;
+	    seqPad?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RenameRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.find = source["find"];
+	        this.replace = source["replace"];
+	        this.case = source["case"];
+	        this.prefix = source["prefix"];
+	        this.suffix = source["suffix"];
+	        this.extension = source["extension"];
+	        this.seqStart = source["seqStart"];
+	        this.seqStep = source["seqStep"];
+	        this.seqPad = source["seqPad"];
+	    }
+	}
+	export class RenamePreview {
+	    path: string;
+	    newName: string;
+	    conflict?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RenamePreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.newName = source["newName"];
+	        this.conflict = source["conflict"];
+	    }
+	}
 
 }
 
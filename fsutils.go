@@ -42,3 +42,51 @@ type TruncateFS interface {
 	fs.FS
 	Truncate(name string, size int64) error
 }
+
+// An interface to get FileInfo of symbolic links themselves.
+type LstatFS interface {
+	fs.FS
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// Lstat returns FileInfo of name without following a symbolic link if fsys supports LstatFS.
+func Lstat(fsys fs.StatFS, name string) (fs.FileInfo, error) {
+	if l, ok := fsys.(LstatFS); ok {
+		return l.Lstat(name)
+	}
+	return fsys.Stat(name)
+}
+
+// An interface to move files to the trash instead of removing them permanently.
+type TrashFS interface {
+	fs.FS
+	MoveToTrash(name string) error
+}
+
+func resolveVolume(fsys fs.FS, name string) (fs.FS, string) {
+	if r, ok := fsys.(interface{ ResolveVolume(string) (Volume, string) }); ok {
+		return r.ResolveVolume(name)
+	}
+	return fsys, name
+}
+
+// RealPath returns the path in the OS file system if name is backed by a local file.
+func RealPath(fsys fs.FS, name string) (string, bool) {
+	fsys, name = resolveVolume(fsys, name)
+	if rv, ok := fsys.(interface{ RealPath(string) string }); ok {
+		return rv.RealPath(name), true
+	}
+	return "", false
+}
+
+// MediaSource returns a local path or URL which external programs like ffmpeg can read directly.
+func MediaSource(fsys fs.FS, name string) (string, bool) {
+	if p, ok := RealPath(fsys, name); ok {
+		return p, true
+	}
+	fsys, name = resolveVolume(fsys, name)
+	if u, ok := fsys.(interface{ URL(string) (string, bool) }); ok {
+		return u.URL(name)
+	}
+	return "", false
+}
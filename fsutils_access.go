@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// AccessRule allows caps under Path (and Path itself). "." or "" means the whole storage.
+type AccessRule struct {
+	Path string
+	Caps Capability
+}
+
+func (r *AccessRule) contains(name string) bool {
+	p := path.Clean(r.Path)
+	return p == "." || p == "/" || name == p || strings.HasPrefix(name, p+"/")
+}
+
+// leadsTo reports whether name is an ancestor directory of Path.
+func (r *AccessRule) leadsTo(name string) bool {
+	p := path.Clean(r.Path)
+	return name == "." || strings.HasPrefix(p, name+"/")
+}
+
+// accessVolume limits v to the paths and capabilities allowed by rules.
+// Ancestor directories of allowed paths can be listed, but show only the entries leading to them.
+type accessVolume struct {
+	v     Volume
+	rules []AccessRule
+}
+
+func NewAccessVolume(v Volume, rules []AccessRule) Volume {
+	return &accessVolume{v: v, rules: rules}
+}
+
+func (a *accessVolume) allowed(name string) Capability {
+	var caps Capability
+	for _, r := range a.rules {
+		if r.contains(name) {
+			caps |= r.Caps
+		}
+	}
+	if caps&Remove != 0 {
+		caps |= Trash
+	}
+	return caps
+}
+
+func (a *accessVolume) isAncestor(name string) bool {
+	for _, r := range a.rules {
+		if r.leadsTo(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *accessVolume) visible(name string) bool {
+	return a.allowed(name)&(Read|Stat) != 0 || a.isAncestor(name)
+}
+
+func (a *accessVolume) check(op, name string, c Capability) error {
+	if a.allowed(name)&c != c {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (a *accessVolume) Caps() Capability {
+	return a.CapsAt(".")
+}
+
+func (a *accessVolume) CapsAt(name string) Capability {
+	caps := CapsAt(a.v, name) & a.allowed(name)
+	if a.isAncestor(name) {
+		caps |= CapsAt(a.v, name) & CapReadOnly
+	}
+	return caps
+}
+
+// ResolveVolume returns the underlying volume only if name can be read. Otherwise a itself is returned.
+func (a *accessVolume) ResolveVolume(name string) (Volume, string) {
+	if a.allowed(name)&Read == 0 {
+		return a, name
+	}
+	if r, ok := a.v.(interface{ ResolveVolume(string) (Volume, string) }); ok {
+		return r.ResolveVolume(name)
+	}
+	return a.v, name
+}
+
+func (a *accessVolume) IsMountPoint(name string) bool {
+	m, ok := a.v.(interface{ IsMountPoint(string) bool })
+	return ok && m.IsMountPoint(name)
+}
+
+func (a *accessVolume) Open(name string) (fs.File, error) {
+	if a.allowed(name)&Read != 0 {
+		return a.v.Open(name)
+	}
+	if !a.isAncestor(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	f, err := a.v.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return &accessDir{ReadDirFile: d, a: a, name: name}, nil
+}
+
+func (a *accessVolume) Stat(name string) (fs.FileInfo, error) {
+	if !a.visible(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrPermission}
+	}
+	return a.v.Stat(name)
+}
+
+func (a *accessVolume) Lstat(name string) (fs.FileInfo, error) {
+	if !a.visible(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrPermission}
+	}
+	return Lstat(a.v, name)
+}
+
+func (a *accessVolume) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !a.visible(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	entries, err := fs.ReadDir(a.v, name)
+	if a.allowed(name)&Read != 0 {
+		return entries, err
+	}
+	return a.filter(name, entries), err
+}
+
+func (a *accessVolume) filter(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	result := []fs.DirEntry{}
+	for _, e := range entries {
+		if a.visible(path.Join(dir, e.Name())) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (a *accessVolume) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	need := Write
+	if flag&os.O_CREATE != 0 {
+		if _, err := a.v.Stat(name); errors.Is(err, fs.ErrNotExist) {
+			need |= Create
+		}
+	}
+	if flag&os.O_APPEND != 0 {
+		need |= Append
+	}
+	if err := a.check("open", name, need); err != nil {
+		return nil, err
+	}
+	return a.v.OpenWriter(name, flag)
+}
+
+func (a *accessVolume) Truncate(name string, size int64) error {
+	if err := a.check("truncate", name, Truncate); err != nil {
+		return err
+	}
+	return a.v.Truncate(name, size)
+}
+
+func (a *accessVolume) Remove(name string) error {
+	if err := a.check("remove", name, Remove); err != nil {
+		return err
+	}
+	return a.v.Remove(name)
+}
+
+func (a *accessVolume) MoveToTrash(name string) error {
+	if err := a.check("trash", name, Trash); err != nil {
+		return err
+	}
+	t, ok := a.v.(TrashFS)
+	if !ok {
+		return &fs.PathError{Op: "trash", Path: name, Err: ErrInvalidOp}
+	}
+	return t.MoveToTrash(name)
+}
+
+func (a *accessVolume) Mkdir(name string, mode fs.FileMode) error {
+	if err := a.check("mkdir", name, Mkdir); err != nil {
+		return err
+	}
+	return a.v.Mkdir(name, mode)
+}
+
+func (a *accessVolume) Rename(name, newName string) error {
+	if err := a.check("rename", name, Rename); err != nil {
+		return err
+	}
+	if err := a.check("rename", newName, Rename); err != nil {
+		return err
+	}
+	return a.v.Rename(name, newName)
+}
+
+type accessDir struct {
+	fs.ReadDirFile
+	a    *accessVolume
+	name string
+}
+
+func (d *accessDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	for {
+		entries, err := d.ReadDirFile.ReadDir(count)
+		entries = d.a.filter(d.name, entries)
+		if len(entries) > 0 || err != nil || count <= 0 {
+			return entries, err
+		}
+	}
+}
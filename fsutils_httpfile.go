@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpFileInfo is a fs.FileInfo built from HTTP headers or directory listings.
+type httpFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (i *httpFileInfo) Name() string       { return i.name }
+func (i *httpFileInfo) Size() int64        { return i.size }
+func (i *httpFileInfo) ModTime() time.Time { return i.modTime }
+func (i *httpFileInfo) IsDir() bool        { return i.isDir }
+func (i *httpFileInfo) Sys() any           { return nil }
+
+// Mode reports writable permissions. Whether the volume is writable is reported by Caps.
+func (i *httpFileInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+func (i *httpFileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *httpFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// httpStatusError converts an unexpected HTTP status to an error comparable with fs errors.
+func httpStatusError(res *http.Response) error {
+	switch res.StatusCode {
+	case http.StatusNotFound, http.StatusGone, http.StatusConflict:
+		return fs.ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fs.ErrPermission
+	case http.StatusPreconditionFailed:
+		return fs.ErrExist
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return ErrInvalidOp
+	}
+	return fmt.Errorf("unexpected http status: %s", res.Status)
+}
+
+// rangeOpener requests the content from offset. end < 0 means until EOF.
+type rangeOpener func(offset, end int64) (*http.Response, error)
+
+func rangeHeader(offset, end int64) string {
+	if end < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, end)
+}
+
+// isPartialContent reports whether the Range request was honored.
+// Some servers reply 200 with a Content-Range header.
+func isPartialContent(res *http.Response) bool {
+	return res.StatusCode == http.StatusPartialContent || res.Header.Get("Content-Range") != ""
+}
+
+// httpRangeFile is a seekable fs.File that reads content with HTTP Range requests.
+// The response body is reused for sequential reads.
+type httpRangeFile struct {
+	info    *httpFileInfo
+	open    rangeOpener
+	pos     int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+func (f *httpRangeFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *httpRangeFile) Read(p []byte) (int, error) {
+	if f.pos >= f.info.size && f.info.size >= 0 {
+		return 0, io.EOF
+	}
+	if f.body == nil || f.bodyPos != f.pos {
+		f.closeBody()
+		res, err := f.open(f.pos, -1)
+		if err != nil {
+			return 0, err
+		}
+		if f.pos > 0 && !isPartialContent(res) {
+			// Range is not supported by the server.
+			if _, err := io.CopyN(io.Discard, res.Body, f.pos); err != nil {
+				res.Body.Close()
+				return 0, err
+			}
+		}
+		f.body, f.bodyPos = res.Body, f.pos
+	}
+	n, err := f.body.Read(p)
+	f.pos += int64(n)
+	f.bodyPos = f.pos
+	return n, err
+}
+
+func (f *httpRangeFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if off >= f.info.size && f.info.size >= 0 {
+		return 0, io.EOF
+	}
+	res, err := f.open(off, off+int64(len(p))-1)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if !isPartialContent(res) {
+		return 0, errors.New("range request is not supported")
+	}
+	n, err := io.ReadFull(res.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *httpRangeFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		if f.info.size < 0 {
+			return 0, ErrInvalidOp
+		}
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *httpRangeFile) closeBody() {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+}
+
+func (f *httpRangeFile) Close() error {
+	f.closeBody()
+	return nil
+}
+
+// httpDir is a fs.ReadDirFile for a directory listing fetched on demand.
+type httpDir struct {
+	info    fs.FileInfo
+	list    func() ([]fs.DirEntry, error)
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+func (d *httpDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *httpDir) Close() error               { return nil }
+func (d *httpDir) Read([]byte) (int, error)   { return 0, ErrInvalidOp }
+
+func (d *httpDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		entries, err := d.list()
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// escapePath percent-encodes each segment of a slash separated path.
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
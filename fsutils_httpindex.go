@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type HTTPIndexConfig struct {
+	URL      string `json:"url"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+const httpIndexMaxListingSize = 16 * 1024 * 1024
+
+var httpIndexTimeLayouts = []string{"02-Jan-2006 15:04", "02-Jan-2006 15:04:05", "2006-01-02 15:04", "2006-01-02 15:04:05"}
+
+// httpIndexResponseTimeout limits waiting for the response headers. Bodies of large files are read without a deadline.
+const httpIndexResponseTimeout = 30 * time.Second
+
+// httpIndexFS is a read-only Volume on a HTTP server with directory listings (e.g. Apache/nginx autoindex).
+type httpIndexFS struct {
+	base     *url.URL
+	user     string
+	password string
+	client   *http.Client
+}
+
+func NewHTTPIndexFS(conf *HTTPIndexConfig) (*httpIndexFS, error) {
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, &url.Error{Op: "parse", URL: conf.URL, Err: fs.ErrInvalid}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = httpIndexResponseTimeout
+	return &httpIndexFS{base: u, user: conf.User, password: conf.Password, client: &http.Client{Transport: transport}}, nil
+}
+
+func (fsys *httpIndexFS) Caps() Capability {
+	return CapReadOnly
+}
+
+func (fsys *httpIndexFS) url(name string, dir bool) *url.URL {
+	u := *fsys.base
+	if name != "." {
+		u.Path += "/" + name
+	}
+	if dir {
+		u.Path += "/"
+	}
+	return &u
+}
+
+// URL returns the URL of the file for external programs like ffmpeg.
+// It fails if a password is required, because the arguments of programs are visible to other users.
+func (fsys *httpIndexFS) URL(name string) (string, bool) {
+	if fsys.user != "" {
+		return "", false
+	}
+	return fsys.url(name, false).String(), true
+}
+
+func (fsys *httpIndexFS) do(method string, u *url.URL, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if fsys.user != "" {
+		req.SetBasicAuth(fsys.user, fsys.password)
+	}
+	return fsys.client.Do(req)
+}
+
+func (fsys *httpIndexFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return fsys.stat(name)
+}
+
+func (fsys *httpIndexFS) stat(name string) (*httpFileInfo, error) {
+	if name == "." {
+		return &httpFileInfo{name: ".", isDir: true}, nil
+	}
+	res, err := fsys.do("HEAD", fsys.url(name, false), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		// Try as a directory. Servers usually redirect to the URL with a trailing slash, but not always.
+		res, err = fsys.do("HEAD", fsys.url(name, true), nil)
+		if err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+		}
+		res.Body.Close()
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: httpStatusError(res)}
+	}
+	modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	if strings.HasSuffix(res.Request.URL.Path, "/") {
+		return &httpFileInfo{name: path.Base(name), modTime: modTime, isDir: true}, nil
+	}
+	size := res.ContentLength
+	if size < 0 {
+		size = fsys.probeSize(name)
+	}
+	return &httpFileInfo{name: path.Base(name), size: size, modTime: modTime}, nil
+}
+
+// probeSize gets the size from Content-Range of a Range request, for servers which don't send Content-Length to HEAD.
+// It returns -1 if the size is unknown.
+func (fsys *httpIndexFS) probeSize(name string) int64 {
+	res, err := fsys.do("GET", fsys.url(name, false), http.Header{"Range": {rangeHeader(0, 0)}})
+	if err != nil {
+		return -1
+	}
+	res.Body.Close()
+	// "bytes 0-0/1234"
+	_, total, ok := strings.Cut(res.Header.Get("Content-Range"), "/")
+	if size, err := strconv.ParseInt(total, 10, 64); ok && err == nil {
+		return size
+	}
+	if res.StatusCode == http.StatusOK {
+		return res.ContentLength
+	}
+	return -1
+}
+
+func (fsys *httpIndexFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	res, err := fsys.do("GET", fsys.url(name, true), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: httpStatusError(res)}
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, httpIndexMaxListingSize))
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	var entries []*httpFileInfo
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") || bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		entries, err = parseJSONIndex(b)
+	} else {
+		entries = parseHTMLIndex(b, res.Request.URL)
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	result := make([]fs.DirEntry, len(entries))
+	for i, e := range entries {
+		if e.modTime.IsZero() {
+			result[i] = &httpIndexEntry{httpFileInfo: e, fsys: fsys, path: path.Join(name, e.name)}
+		} else {
+			result[i] = e
+		}
+	}
+	return result, nil
+}
+
+// httpIndexEntry is an entry of a listing without file details. Info() gets them with HEAD.
+type httpIndexEntry struct {
+	*httpFileInfo
+	fsys *httpIndexFS
+	path string
+}
+
+func (e *httpIndexEntry) Info() (fs.FileInfo, error) {
+	return e.fsys.stat(e.path)
+}
+
+// parseJSONIndex parses the listing of nginx "autoindex_format json".
+func parseJSONIndex(b []byte) ([]*httpFileInfo, error) {
+	var items []struct {
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		MTime string `json:"mtime"`
+		Size  int64  `json:"size"`
+	}
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, err
+	}
+	var entries []*httpFileInfo
+	for _, item := range items {
+		if item.Name == "" || strings.Contains(item.Name, "/") || item.Name == "." || item.Name == ".." {
+			continue
+		}
+		modTime, _ := http.ParseTime(item.MTime)
+		entries = append(entries, &httpFileInfo{name: item.Name, size: item.Size, modTime: modTime, isDir: item.Type == "directory"})
+	}
+	return entries, nil
+}
+
+// parseHTMLIndex collects links to the children of dir. Modification times and sizes are
+// taken from the text following each link if they look like Apache or nginx listings.
+func parseHTMLIndex(b []byte, dir *url.URL) []*httpFileInfo {
+	dirPath := path.Clean("/" + dir.Path)
+	found := map[string]*httpFileInfo{}
+	var entries []*httpFileInfo
+	var current *httpFileInfo
+	var text strings.Builder
+	flush := func() {
+		if current != nil {
+			parseIndexColumns(current, text.String())
+		}
+		current = nil
+		text.Reset()
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(b))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tag, hasAttr := z.TagName()
+		switch {
+		case tt == html.StartTagToken && string(tag) == "a" && hasAttr:
+			flush()
+			var href string
+			for {
+				k, v, more := z.TagAttr()
+				if string(k) == "href" {
+					href = string(v)
+				}
+				if !more {
+					break
+				}
+			}
+			u, err := dir.Parse(href)
+			if err != nil || u.Host != dir.Host || u.RawQuery != "" {
+				continue
+			}
+			p := strings.TrimSuffix(u.Path, "/")
+			if path.Dir(p) != dirPath || p == dirPath {
+				continue
+			}
+			name := path.Base(p)
+			if found[name] == nil {
+				found[name] = &httpFileInfo{name: name, size: -1, isDir: strings.HasSuffix(u.Path, "/")}
+				entries = append(entries, found[name])
+			}
+			current = found[name]
+		case tt == html.EndTagToken && (string(tag) == "tr" || string(tag) == "li"):
+			flush()
+		case tt == html.TextToken && current != nil:
+			text.Write(z.Text())
+			text.WriteByte(' ')
+		}
+	}
+	flush()
+	for _, e := range entries {
+		if e.isDir || e.size < 0 {
+			e.size = 0
+		}
+	}
+	return entries
+}
+
+// parseIndexColumns reads "date time size" columns such as "19-Oct-2026 06:10    1234" or "2026-10-19 06:10  1.2K".
+func parseIndexColumns(info *httpFileInfo, s string) {
+	fields := strings.Fields(s)
+	for i := 0; i+1 < len(fields); i++ {
+		for _, layout := range httpIndexTimeLayouts {
+			t, err := time.Parse(layout, fields[i]+" "+fields[i+1])
+			if err != nil {
+				continue
+			}
+			if info.modTime.IsZero() {
+				info.modTime = t
+			}
+			if i+2 < len(fields) && info.size < 0 {
+				if size, ok := parseHumanSize(fields[i+2]); ok {
+					info.size = size
+				}
+			}
+			return
+		}
+	}
+}
+
+func parseHumanSize(s string) (int64, bool) {
+	unit := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		unit = 1024
+	case "M":
+		unit = 1024 * 1024
+	case "G":
+		unit = 1024 * 1024 * 1024
+	case "T":
+		unit = 1024 * 1024 * 1024 * 1024
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int64(n * float64(unit)), true
+}
+
+func (fsys *httpIndexFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.isDir {
+		return &httpDir{info: info, list: func() ([]fs.DirEntry, error) { return fsys.ReadDir(name) }}, nil
+	}
+	u := fsys.url(name, false)
+	return &httpRangeFile{info: info, open: func(offset, end int64) (*http.Response, error) {
+		res, err := fsys.do("GET", u, http.Header{"Range": {rangeHeader(offset, end)}})
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+			res.Body.Close()
+			return nil, &fs.PathError{Op: "read", Path: name, Err: httpStatusError(res)}
+		}
+		return res, nil
+	}}, nil
+}
+
+func (fsys *httpIndexFS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: ErrInvalidOp}
+}
+
+func (fsys *httpIndexFS) Truncate(name string, size int64) error {
+	return &fs.PathError{Op: "truncate", Path: name, Err: ErrInvalidOp}
+}
+
+func (fsys *httpIndexFS) Mkdir(name string, mode fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: ErrInvalidOp}
+}
+
+func (fsys *httpIndexFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: ErrInvalidOp}
+}
+
+func (fsys *httpIndexFS) Rename(name, newName string) error {
+	return &fs.PathError{Op: "rename", Path: name, Err: ErrInvalidOp}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const apacheIndex = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html><head><title>Index of /apache</title></head><body><h1>Index of /apache</h1>
+<table>
+<tr><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th></tr>
+<tr><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td></tr>
+<tr><td><a href="a.txt">a.txt</a></td><td align="right">2026-10-19 06:10  </td><td align="right">1.2K</td></tr>
+<tr><td><a href="sub/">sub/</a></td><td align="right">2026-10-18 05:00  </td><td align="right">  - </td></tr>
+</table></body></html>`
+
+const nginxIndex = `<html><head><title>Index of /nginx/</title></head><body><h1>Index of /nginx/</h1><hr><pre><a href="../">../</a>
+<a href="a.txt">a.txt</a>                                              19-Oct-2026 06:10                1234
+<a href="sub/">sub/</a>                                               18-Oct-2026 05:00                   -
+</pre><hr></body></html>`
+
+const jsonIndex = `[
+{ "name":"a.txt", "type":"file", "mtime":"Mon, 19 Oct 2026 06:10:00 GMT", "size":1234 },
+{ "name":"sub", "type":"directory", "mtime":"Sun, 18 Oct 2026 05:00:00 GMT" }
+]`
+
+func newHTTPIndexServer() *httptest.Server {
+	mux := http.NewServeMux()
+	serve := func(contentType, body string) http.HandlerFunc {
+		return func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", contentType)
+			res.Write([]byte(body))
+		}
+	}
+	mux.Handle("/apache/", serve("text/html", apacheIndex))
+	mux.Handle("/nginx/", serve("text/html", nginxIndex))
+	mux.Handle("/json/", serve("application/json", jsonIndex))
+	// Content-Length is not sent to HEAD, like chunked responses.
+	mux.HandleFunc("/chunked/file.bin", func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Range") != "" {
+			res.Header().Set("Content-Range", "bytes 0-0/5000")
+			res.WriteHeader(http.StatusPartialContent)
+			res.Write([]byte{0})
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPIndexFSReadDir(t *testing.T) {
+	server := newHTTPIndexServer()
+	defer server.Close()
+	for _, dir := range []string{"apache", "nginx", "json"} {
+		size := int64(1234)
+		if dir == "apache" {
+			size = 1228 // 1.2K
+		}
+		want := []httpFileInfo{
+			{name: "a.txt", size: size, modTime: time.Date(2026, 10, 19, 6, 10, 0, 0, time.UTC)},
+			{name: "sub", isDir: true, modTime: time.Date(2026, 10, 18, 5, 0, 0, 0, time.UTC)},
+		}
+		v, err := NewHTTPIndexFS(&HTTPIndexConfig{URL: server.URL + "/" + dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := v.ReadDir(".")
+		if err != nil {
+			t.Fatal(dir, err)
+		}
+		if len(entries) != len(want) {
+			t.Errorf("%s: got %v, want %d entries", dir, entryNames(entries), len(want))
+			continue
+		}
+		for i, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				t.Error(dir, err)
+				continue
+			}
+			w := &want[i]
+			if info.Name() != w.name || info.IsDir() != w.isDir || info.Size() != w.size || !info.ModTime().Equal(w.modTime) {
+				t.Errorf("%s: got %s %v %d %v, want %s %v %d %v", dir, info.Name(), info.IsDir(), info.Size(), info.ModTime(),
+					w.name, w.isDir, w.size, w.modTime)
+			}
+		}
+	}
+}
+
+func TestHTTPIndexFSStatWithoutLength(t *testing.T) {
+	server := newHTTPIndexServer()
+	defer server.Close()
+	v, err := NewHTTPIndexFS(&HTTPIndexConfig{URL: server.URL + "/chunked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := v.Stat("file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 5000 {
+		t.Errorf("size %d, want 5000", info.Size())
+	}
+}
+
+func TestParseIndexColumns(t *testing.T) {
+	tests := []struct {
+		text    string
+		modTime time.Time
+		size    int64
+	}{
+		{"19-Oct-2026 06:10    1234", time.Date(2026, 10, 19, 6, 10, 0, 0, time.UTC), 1234},
+		{"19-Oct-2026 06:10:05 -", time.Date(2026, 10, 19, 6, 10, 5, 0, time.UTC), -1},
+		{"2026-10-19 06:10  1.5M", time.Date(2026, 10, 19, 6, 10, 0, 0, time.UTC), 1536 * 1024},
+		{"description only", time.Time{}, -1},
+	}
+	for _, tt := range tests {
+		info := &httpFileInfo{size: -1}
+		parseIndexColumns(info, tt.text)
+		if !info.modTime.Equal(tt.modTime) || info.size != tt.size {
+			t.Errorf("%q: got %v %d, want %v %d", tt.text, info.modTime, info.size, tt.modTime, tt.size)
+		}
+	}
+}
+
+func TestParseHumanSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int64
+		ok   bool
+	}{
+		{"1234", 1234, true},
+		{"1K", 1024, true},
+		{"1.5k", 1536, true},
+		{"2M", 2 * 1024 * 1024, true},
+		{"1G", 1024 * 1024 * 1024, true},
+		{"1T", 1024 * 1024 * 1024 * 1024, true},
+		{"-", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		size, ok := parseHumanSize(tt.s)
+		if size != tt.size || ok != tt.ok {
+			t.Errorf("%q: got %d %v, want %d %v", tt.s, size, ok, tt.size, tt.ok)
+		}
+	}
+}
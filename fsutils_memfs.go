@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotEmpty = errors.New("directory not empty")
+
+type memNode struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	data     []byte
+	children map[string]*memNode
+}
+
+func (n *memNode) info() *memFileInfo {
+	return &memFileInfo{name: n.name, mode: n.mode, modTime: n.modTime, size: int64(len(n.data))}
+}
+
+type memFileInfo struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	size    int64
+}
+
+func (i *memFileInfo) Name() string               { return i.name }
+func (i *memFileInfo) Size() int64                { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode          { return i.mode }
+func (i *memFileInfo) ModTime() time.Time         { return i.modTime }
+func (i *memFileInfo) IsDir() bool                { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any                   { return nil }
+func (i *memFileInfo) Type() fs.FileMode          { return i.mode.Type() }
+func (i *memFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// memFS is a writable in-memory Volume.
+type memFS struct {
+	mutex sync.RWMutex
+	root  *memNode
+}
+
+func NewMemFS() *memFS {
+	return &memFS{root: &memNode{name: ".", mode: fs.ModeDir | 0777, modTime: time.Now(), children: map[string]*memNode{}}}
+}
+
+// lookup must be called with the lock held.
+func (fsys *memFS) lookup(name string) (*memNode, error) {
+	if name == "." {
+		return fsys.root, nil
+	}
+	n := fsys.root
+	for _, p := range strings.Split(name, "/") {
+		if n.children == nil {
+			return nil, fs.ErrNotExist
+		}
+		c, ok := n.children[p]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		n = c
+	}
+	return n, nil
+}
+
+// lookupParent must be called with the lock held.
+func (fsys *memFS) lookupParent(name string) (*memNode, string, error) {
+	if name == "." {
+		return nil, "", fs.ErrInvalid
+	}
+	dir, base := path.Split(name)
+	parent, err := fsys.lookup(path.Clean(dir))
+	if err != nil {
+		return nil, "", err
+	}
+	if !parent.mode.IsDir() {
+		return nil, "", fs.ErrNotExist
+	}
+	return parent, base, nil
+}
+
+func (fsys *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.RLock()
+	defer fsys.mutex.RUnlock()
+	n, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if n.mode.IsDir() {
+		return &memDir{info: n.info(), entries: n.entries()}, nil
+	}
+	data := make([]byte, len(n.data))
+	copy(data, n.data)
+	return &memFile{info: n.info(), data: data}, nil
+}
+
+func (fsys *memFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.RLock()
+	defer fsys.mutex.RUnlock()
+	n, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return n.info(), nil
+}
+
+func (fsys *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.RLock()
+	defer fsys.mutex.RUnlock()
+	n, err := fsys.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrInvalidOp}
+	}
+	return n.entries(), nil
+}
+
+func (n *memNode) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, c := range n.children {
+		entries = append(entries, c.info())
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+func (fsys *memFS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	parent, base, err := fsys.lookupParent(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	n, exists := parent.children[base]
+	if exists && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	if exists && n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrInvalidOp}
+	}
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		n = &memNode{name: base, mode: 0666, modTime: time.Now()}
+		parent.children[base] = n
+		parent.modTime = n.modTime
+	}
+	if flag&os.O_TRUNC != 0 {
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &memWriter{fsys: fsys, node: n, append: flag&os.O_APPEND != 0}, nil
+}
+
+func (fsys *memFS) Truncate(name string, size int64) error {
+	if !fs.ValidPath(name) || size < 0 {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	n, err := fsys.lookup(name)
+	if err != nil {
+		return &fs.PathError{Op: "truncate", Path: name, Err: err}
+	}
+	if n.mode.IsDir() {
+		return &fs.PathError{Op: "truncate", Path: name, Err: ErrInvalidOp}
+	}
+	n.data = resizeBytes(n.data, int(size))
+	n.modTime = time.Now()
+	return nil
+}
+
+func (fsys *memFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	parent, base, err := fsys.lookupParent(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	n, ok := parent.children[base]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if len(n.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+	}
+	delete(parent.children, base)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (fsys *memFS) Mkdir(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	parent, base, err := fsys.lookupParent(name)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if _, exists := parent.children[base]; exists {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	n := &memNode{name: base, mode: fs.ModeDir | mode.Perm(), modTime: time.Now(), children: map[string]*memNode{}}
+	parent.children[base] = n
+	parent.modTime = n.modTime
+	return nil
+}
+
+// Rename follows POSIX rename(2): an existing file or empty directory at newName is replaced.
+func (fsys *memFS) Rename(name, newName string) error {
+	if !fs.ValidPath(name) || !fs.ValidPath(newName) || name == "." || newName == "." {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == newName {
+		return nil
+	}
+	if strings.HasPrefix(newName, name+"/") {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	parent, base, err := fsys.lookupParent(name)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: name, Err: err}
+	}
+	n, ok := parent.children[base]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrNotExist}
+	}
+	newParent, newBase, err := fsys.lookupParent(newName)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: newName, Err: err}
+	}
+	if old, exists := newParent.children[newBase]; exists {
+		if old.mode.IsDir() != n.mode.IsDir() {
+			return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+		}
+		if len(old.children) > 0 {
+			return &fs.PathError{Op: "rename", Path: newName, Err: ErrNotEmpty}
+		}
+	}
+	delete(parent.children, base)
+	n.name = newBase
+	newParent.children[newBase] = n
+	now := time.Now()
+	parent.modTime = now
+	newParent.modTime = now
+	return nil
+}
+
+func resizeBytes(b []byte, size int) []byte {
+	if size <= len(b) {
+		return b[:size]
+	}
+	return append(b, make([]byte, size-len(b))...)
+}
+
+type memWriter struct {
+	fsys   *memFS
+	node   *memNode
+	pos    int64
+	append bool
+	closed bool
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+	w.fsys.mutex.Lock()
+	defer w.fsys.mutex.Unlock()
+	n := w.node
+	if w.append {
+		w.pos = int64(len(n.data))
+	}
+	end := int(w.pos) + len(p)
+	if end > len(n.data) {
+		n.data = resizeBytes(n.data, end)
+	}
+	copy(n.data[w.pos:], p)
+	w.pos = int64(end)
+	n.modTime = time.Now()
+	return len(p), nil
+}
+
+func (w *memWriter) Seek(offset int64, whence int) (int64, error) {
+	w.fsys.mutex.RLock()
+	size := int64(len(w.node.data))
+	w.fsys.mutex.RUnlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += w.pos
+	case io.SeekEnd:
+		offset += size
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	w.pos = offset
+	return offset, nil
+}
+
+func (w *memWriter) Close() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+	return nil
+}
+
+type memFile struct {
+	info *memFileInfo
+	data []byte
+	pos  int64
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.pos >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[f.pos:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fs.ErrInvalid
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	if offset < 0 {
+		return 0, fs.ErrInvalid
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+type memDir struct {
+	info    *memFileInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+func (d *memDir) Read([]byte) (int, error)   { return 0, ErrInvalidOp }
+
+func (d *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.pos += count
+	return rest[:count], nil
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestMemFS(t *testing.T) {
+	v := NewMemFS()
+	if err := v.Mkdir("test", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkVolume(v, "test"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type mountPoint struct {
+	path string
+	v    Volume
+}
+
+// mountFS is a Volume that routes paths to the volumes mounted at the longest matching prefix.
+type mountFS struct {
+	root   Volume
+	mutex  sync.RWMutex
+	mounts []mountPoint
+}
+
+func NewMountFS(root fs.FS) *mountFS {
+	return &mountFS{root: WrapVolume(root)}
+}
+
+func (m *mountFS) Mount(name string, v fs.FS) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mount", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, mp := range m.mounts {
+		if mp.path == name {
+			return &fs.PathError{Op: "mount", Path: name, Err: fs.ErrExist}
+		}
+	}
+	m.mounts = append(m.mounts, mountPoint{path: name, v: WrapVolume(v)})
+	sort.Slice(m.mounts, func(i, j int) bool { return len(m.mounts[i].path) > len(m.mounts[j].path) })
+	return nil
+}
+
+func (m *mountFS) Unmount(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i, mp := range m.mounts {
+		if mp.path == name {
+			m.mounts = append(m.mounts[:i], m.mounts[i+1:]...)
+			return nil
+		}
+	}
+	return &fs.PathError{Op: "unmount", Path: name, Err: fs.ErrNotExist}
+}
+
+// ResolveVolume returns the volume containing name and the path relative to it.
+func (m *mountFS) ResolveVolume(name string) (Volume, string) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, mp := range m.mounts {
+		if name == mp.path {
+			return mp.v, "."
+		}
+		if strings.HasPrefix(name, mp.path+"/") {
+			return mp.v, name[len(mp.path)+1:]
+		}
+	}
+	return m.root, name
+}
+
+// IsMountPoint reports whether name is the root of a mounted volume.
+func (m *mountFS) IsMountPoint(name string) bool {
+	if name == "." || name == "" {
+		return true
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, mp := range m.mounts {
+		if mp.path == name {
+			return true
+		}
+	}
+	return false
+}
+
+// childMounts returns the names of mount points directly under dir.
+func (m *mountFS) childMounts(dir string) []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var names []string
+	for _, mp := range m.mounts {
+		parent, name := "", mp.path
+		if i := strings.LastIndex(mp.path, "/"); i >= 0 {
+			parent, name = mp.path[:i], mp.path[i+1:]
+		}
+		if parent == dir || dir == "." && parent == "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (m *mountFS) Caps() Capability {
+	return Caps(m.root)
+}
+
+// CapsAt returns the capabilities of the volume containing name.
+func (m *mountFS) CapsAt(name string) Capability {
+	v, _ := m.ResolveVolume(name)
+	return Caps(v)
+}
+
+func (m *mountFS) mountPointInfo(name string) *mountPointEntry {
+	v, _ := m.ResolveVolume(name)
+	e := &mountPointEntry{name: name, mode: fs.ModeDir | 0777}
+	if st, err := v.Stat("."); err == nil {
+		e.mode, e.modTime = st.Mode(), st.ModTime()
+	}
+	return e
+}
+
+func (m *mountFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	v, rel := m.ResolveVolume(name)
+	f, err := v.Open(rel)
+	isMountPoint := name != "." && m.IsMountPoint(name)
+	if err != nil || !isMountPoint && len(m.childMounts(name)) == 0 {
+		return f, err
+	}
+	entries, err := m.ReadDir(name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d := &mountDir{File: f, entries: entries}
+	if isMountPoint {
+		d.info = m.mountPointInfo(name)
+	}
+	return d, nil
+}
+
+func (m *mountFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." && m.IsMountPoint(name) {
+		return m.mountPointInfo(name), nil
+	}
+	v, rel := m.ResolveVolume(name)
+	return v.Stat(rel)
+}
+
+func (m *mountFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." && m.IsMountPoint(name) {
+		return m.mountPointInfo(name), nil
+	}
+	v, rel := m.ResolveVolume(name)
+	return Lstat(v, rel)
+}
+
+func (m *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	v, rel := m.ResolveVolume(name)
+	entries, err := fs.ReadDir(v, rel)
+	mounts := m.childMounts(name)
+	if len(mounts) == 0 {
+		return entries, err
+	}
+	if err != nil {
+		entries = nil
+	}
+	for _, mp := range mounts {
+		if name != "." {
+			mp = name + "/" + mp
+		}
+		entries = append(entries, m.mountPointInfo(mp))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for i := len(entries) - 1; i > 0; i-- {
+		if entries[i].Name() == entries[i-1].Name() {
+			if _, ok := entries[i].(*mountPointEntry); ok {
+				entries[i-1] = entries[i]
+			}
+			entries = append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries, nil
+}
+
+func (m *mountFS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	v, name := m.ResolveVolume(name)
+	return v.OpenWriter(name, flag)
+}
+
+func (m *mountFS) Truncate(name string, size int64) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrInvalid}
+	}
+	v, name := m.ResolveVolume(name)
+	return v.Truncate(name, size)
+}
+
+func (m *mountFS) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if m.IsMountPoint(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	v, name := m.ResolveVolume(name)
+	return v.Remove(name)
+}
+
+func (m *mountFS) MoveToTrash(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "trash", Path: name, Err: fs.ErrInvalid}
+	}
+	if m.IsMountPoint(name) {
+		return &fs.PathError{Op: "trash", Path: name, Err: fs.ErrPermission}
+	}
+	v, name := m.ResolveVolume(name)
+	t, ok := v.(TrashFS)
+	if !ok {
+		return &fs.PathError{Op: "trash", Path: name, Err: ErrInvalidOp}
+	}
+	return t.MoveToTrash(name)
+}
+
+func (m *mountFS) Mkdir(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	v, name := m.ResolveVolume(name)
+	return v.Mkdir(name, mode)
+}
+
+func (m *mountFS) Rename(name, newName string) error {
+	if !fs.ValidPath(name) || !fs.ValidPath(newName) {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrInvalid}
+	}
+	if m.IsMountPoint(name) || m.IsMountPoint(newName) {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrPermission}
+	}
+	v, name := m.ResolveVolume(name)
+	v2, newName := m.ResolveVolume(newName)
+	if v != v2 {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrInvalid}
+	}
+	return v.Rename(name, newName)
+}
+
+type mountPointEntry struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (d *mountPointEntry) Name() string               { return d.name[strings.LastIndex(d.name, "/")+1:] }
+func (d *mountPointEntry) IsDir() bool                { return true }
+func (d *mountPointEntry) Type() fs.FileMode          { return fs.ModeDir }
+func (d *mountPointEntry) Info() (fs.FileInfo, error) { return d, nil }
+func (d *mountPointEntry) Size() int64                { return 0 }
+func (d *mountPointEntry) Mode() fs.FileMode          { return d.mode }
+func (d *mountPointEntry) ModTime() time.Time         { return d.modTime }
+func (d *mountPointEntry) Sys() any                   { return nil }
+
+// mountDir lists mount points together with the entries of the underlying directory.
+type mountDir struct {
+	fs.File
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+func (d *mountDir) Stat() (fs.FileInfo, error) {
+	if d.info != nil {
+		return d.info, nil
+	}
+	return d.File.Stat()
+}
+
+func (d *mountDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(rest))
+	d.pos += count
+	return rest[:count], nil
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint defaults to AWS. e.g. http://localhost:9000
+	Endpoint  string `json:"endpoint,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	// Prefix is the key prefix shown as the root of the volume.
+	Prefix    string `json:"prefix,omitempty"`
+	PathStyle bool   `json:"pathStyle,omitempty"`
+}
+
+const s3PartSize = 8 * 1024 * 1024
+
+// s3ResponseTimeout limits waiting for the response headers. Bodies of large objects are read without a deadline.
+const s3ResponseTimeout = 30 * time.Second
+
+// s3FS is a Volume on an S3 compatible bucket.
+// Directories are common prefixes of keys. Mkdir creates an empty "dir/" object as a marker.
+type s3FS struct {
+	conf   S3Config
+	base   *url.URL
+	prefix string
+	client *http.Client
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func NewS3FS(conf *S3Config) (*s3FS, error) {
+	c := *conf
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+	}
+	if c.Bucket == "" {
+		return nil, errors.New("bucket is not specified")
+	}
+	base, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if !c.PathStyle {
+		base.Host = c.Bucket + "." + base.Host
+	}
+	prefix := strings.Trim(c.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = s3ResponseTimeout
+	fsys := &s3FS{conf: c, base: base, prefix: prefix, client: &http.Client{Transport: transport}}
+	if _, err := fsys.list(fsys.prefix, "/", "", 1); err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
+
+func (fsys *s3FS) Caps() Capability {
+	return Read | Stat | Write | Create | Mkdir | Remove | Rename
+}
+
+func (fsys *s3FS) key(name string) string {
+	if name == "." {
+		return strings.TrimSuffix(fsys.prefix, "/")
+	}
+	return fsys.prefix + name
+}
+
+func (fsys *s3FS) dirPrefix(name string) string {
+	if name == "." {
+		return fsys.prefix
+	}
+	return fsys.prefix + name + "/"
+}
+
+// s3Escape encodes s as specified for SigV4 canonical requests.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && keepSlash {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+func (fsys *s3FS) objectPath(key string) string {
+	p := fsys.base.Path
+	if fsys.conf.PathStyle {
+		p += "/" + fsys.conf.Bucket
+	}
+	return p + "/" + key
+}
+
+func (fsys *s3FS) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, s3Escape(k, false)+"="+s3Escape(query.Get(k), false))
+	}
+	rawQuery := strings.Join(params, "&")
+	escapedPath := s3Escape(fsys.objectPath(key), true)
+
+	u := *fsys.base
+	u.Path, u.RawPath, u.RawQuery = fsys.objectPath(key), escapedPath, rawQuery
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if fsys.conf.AccessKey != "" {
+		fsys.sign(req, escapedPath, rawQuery, sha256Hex(body), time.Now())
+	}
+	return fsys.client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header.
+func (fsys *s3FS) sign(req *http.Request, escapedPath, rawQuery, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + fsys.conf.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, escapedPath, rawQuery,
+		canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + fsys.conf.SecretKey)
+	for _, s := range []string{now.Format("20060102"), fsys.conf.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+fsys.conf.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(hmacSHA256(key, stringToSign)))
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// request sends a request and returns the response body if it succeeded.
+func (fsys *s3FS) request(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, []byte, error) {
+	res, err := fsys.do(method, key, query, header, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, nil, httpStatusError(res)
+	}
+	return res, b, nil
+}
+
+func (fsys *s3FS) list(prefix, delimiter, token string, maxKeys int) (*s3ListResult, error) {
+	q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		q.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		q.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		q.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+	_, b, err := fsys.request("GET", "", q, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result s3ListResult
+	if err := xml.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// hasChildren checks if any key other than the marker exists under the directory.
+func (fsys *s3FS) hasChildren(name string) (exists, notEmpty bool, err error) {
+	prefix := fsys.dirPrefix(name)
+	result, err := fsys.list(prefix, "/", "", 2)
+	if err != nil {
+		return false, false, err
+	}
+	for _, c := range result.Contents {
+		exists = true
+		notEmpty = notEmpty || c.Key != prefix
+	}
+	exists = exists || len(result.CommonPrefixes) > 0
+	notEmpty = notEmpty || len(result.CommonPrefixes) > 0
+	return exists, notEmpty, nil
+}
+
+func (fsys *s3FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return fsys.stat(name)
+}
+
+func (fsys *s3FS) stat(name string) (*httpFileInfo, error) {
+	if name == "." {
+		return &httpFileInfo{name: ".", isDir: true}, nil
+	}
+	res, err := fsys.do("HEAD", fsys.key(name), nil, nil, nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+		return &httpFileInfo{name: path.Base(name), size: res.ContentLength, modTime: modTime}, nil
+	}
+	if res.StatusCode != http.StatusNotFound {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: httpStatusError(res)}
+	}
+	exists, _, err := fsys.hasChildren(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if !exists {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &httpFileInfo{name: path.Base(name), isDir: true}, nil
+}
+
+func (fsys *s3FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := fsys.dirPrefix(name)
+	entries := []fs.DirEntry{}
+	dirs := map[string]bool{}
+	exists := name == "."
+	token := ""
+	for {
+		result, err := fsys.list(prefix, "/", token, 0)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for _, c := range result.Contents {
+			exists = true
+			name := c.Key[len(prefix):]
+			if name == "" {
+				continue // directory marker
+			}
+			if strings.HasSuffix(name, "/") {
+				// Some servers return markers of subdirectories as objects.
+				dirs[strings.TrimSuffix(name, "/")] = true
+				continue
+			}
+			// Last-Modified header of HEAD has only seconds.
+			entries = append(entries, &httpFileInfo{name: name, size: c.Size, modTime: c.LastModified.Truncate(time.Second)})
+		}
+		for _, p := range result.CommonPrefixes {
+			exists = true
+			dirs[strings.TrimSuffix(p.Prefix[len(prefix):], "/")] = true
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	if !exists {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	for dir := range dirs {
+		entries = append(entries, &httpFileInfo{name: dir, isDir: true})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (fsys *s3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.isDir {
+		return &httpDir{info: info, list: func() ([]fs.DirEntry, error) { return fsys.ReadDir(name) }}, nil
+	}
+	return &httpRangeFile{info: info, open: func(offset, end int64) (*http.Response, error) {
+		res, err := fsys.do("GET", fsys.key(name), nil, http.Header{"Range": {rangeHeader(offset, end)}}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+			res.Body.Close()
+			return nil, &fs.PathError{Op: "read", Path: name, Err: httpStatusError(res)}
+		}
+		return res, nil
+	}}, nil
+}
+
+func (fsys *s3FS) checkParent(op, name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, err := fsys.stat(dir)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !info.isDir {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// OpenWriter uploads the object with multipart upload if it is larger than s3PartSize.
+// Objects can not be modified partially, so the content is always replaced and O_APPEND is not supported.
+func (fsys *s3FS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." || flag&os.O_APPEND != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err == nil && (info.isDir || flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	if err != nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		if err := fsys.checkParent("open", name); err != nil {
+			return nil, err
+		}
+	}
+	return &s3Writer{fsys: fsys, name: name, key: fsys.key(name)}, nil
+}
+
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+
+type s3Writer struct {
+	fsys     *s3FS
+	name     string
+	key      string
+	buf      bytes.Buffer
+	uploadID string
+	parts    []s3Part
+	err      error
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf.Write(p)
+	for w.buf.Len() >= s3PartSize && w.err == nil {
+		w.err = w.uploadPart(w.buf.Next(s3PartSize))
+	}
+	if w.err != nil {
+		w.abort()
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func (w *s3Writer) uploadPart(data []byte) error {
+	if w.uploadID == "" {
+		_, b, err := w.fsys.request("POST", w.key, url.Values{"uploads": {""}}, nil, nil)
+		if err != nil {
+			return &fs.PathError{Op: "write", Path: w.name, Err: err}
+		}
+		var result struct{ UploadId string }
+		if err := xml.Unmarshal(b, &result); err != nil {
+			return &fs.PathError{Op: "write", Path: w.name, Err: err}
+		}
+		w.uploadID = result.UploadId
+	}
+	n := len(w.parts) + 1
+	res, _, err := w.fsys.request("PUT", w.key, url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {w.uploadID}}, nil, data)
+	if err != nil {
+		return &fs.PathError{Op: "write", Path: w.name, Err: err}
+	}
+	w.parts = append(w.parts, s3Part{PartNumber: n, ETag: res.Header.Get("ETag")})
+	return nil
+}
+
+func (w *s3Writer) abort() {
+	if w.uploadID != "" {
+		w.fsys.request("DELETE", w.key, url.Values{"uploadId": {w.uploadID}}, nil, nil)
+		w.uploadID = ""
+	}
+}
+
+func (w *s3Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = fs.ErrClosed
+	if w.uploadID == "" {
+		if _, _, err := w.fsys.request("PUT", w.key, nil, nil, w.buf.Bytes()); err != nil {
+			return &fs.PathError{Op: "write", Path: w.name, Err: err}
+		}
+		return nil
+	}
+	if w.buf.Len() > 0 {
+		if err := w.uploadPart(w.buf.Bytes()); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	body, _ := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: w.parts})
+	_, b, err := w.fsys.request("POST", w.key, url.Values{"uploadId": {w.uploadID}}, nil, body)
+	if err == nil && bytes.Contains(b, []byte("<Error>")) {
+		err = errors.New("failed to complete multipart upload")
+	}
+	if err != nil {
+		w.abort()
+		return &fs.PathError{Op: "write", Path: w.name, Err: err}
+	}
+	return nil
+}
+
+func (fsys *s3FS) Truncate(name string, size int64) error {
+	return &fs.PathError{Op: "truncate", Path: name, Err: ErrInvalidOp}
+}
+
+func (fsys *s3FS) Mkdir(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := fsys.stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := fsys.checkParent("mkdir", name); err != nil {
+		return err
+	}
+	if _, _, err := fsys.request("PUT", fsys.dirPrefix(name), nil, nil, nil); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (fsys *s3FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err != nil {
+		return err
+	}
+	key := fsys.key(name)
+	if info.isDir {
+		_, notEmpty, err := fsys.hasChildren(name)
+		if err != nil {
+			return &fs.PathError{Op: "remove", Path: name, Err: err}
+		}
+		if notEmpty {
+			return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+		}
+		key = fsys.dirPrefix(name)
+	}
+	if _, _, err := fsys.request("DELETE", key, nil, nil, nil); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Rename copies objects to the new keys and deletes the old ones. Renaming a directory is not atomic.
+func (fsys *s3FS) Rename(name, newName string) error {
+	if !fs.ValidPath(name) || !fs.ValidPath(newName) || name == "." || newName == "." {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrInvalid}
+	}
+	src, err := fsys.stat(name)
+	if err != nil {
+		return err
+	}
+	if name == newName {
+		return nil
+	}
+	if src.isDir && strings.HasPrefix(newName, name+"/") {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrInvalid}
+	}
+	if dst, err := fsys.stat(newName); err == nil {
+		if dst.isDir != src.isDir {
+			return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+		}
+		if _, notEmpty, err := fsys.hasChildren(newName); err != nil || notEmpty {
+			return &fs.PathError{Op: "rename", Path: newName, Err: ErrNotEmpty}
+		}
+	} else if err := fsys.checkParent("rename", newName); err != nil {
+		return err
+	}
+	if !src.isDir {
+		return fsys.move("rename", name, fsys.key(name), fsys.key(newName))
+	}
+
+	srcPrefix, dstPrefix := fsys.dirPrefix(name), fsys.dirPrefix(newName)
+	token := ""
+	for {
+		result, err := fsys.list(srcPrefix, "", token, 0)
+		if err != nil {
+			return &fs.PathError{Op: "rename", Path: name, Err: err}
+		}
+		for _, c := range result.Contents {
+			if err := fsys.move("rename", name, c.Key, dstPrefix+c.Key[len(srcPrefix):]); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (fsys *s3FS) move(op, name, srcKey, dstKey string) error {
+	source := s3Escape("/"+fsys.conf.Bucket+"/"+srcKey, true)
+	_, b, err := fsys.request("PUT", dstKey, nil, http.Header{"X-Amz-Copy-Source": {source}}, nil)
+	if err == nil && bytes.Contains(b, []byte("<Error>")) {
+		err = errors.New("failed to copy object")
+	}
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if _, _, err := fsys.request("DELETE", srcKey, nil, nil, nil); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeS3Object struct {
+	data    []byte
+	modTime time.Time
+}
+
+// fakeS3 is a path-style S3 bucket in memory. Multipart uploads are not supported.
+type fakeS3 struct {
+	mutex   sync.Mutex
+	bucket  string
+	objects map[string]*fakeS3Object
+}
+
+func (s *fakeS3) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key, ok := strings.CutPrefix(req.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		http.NotFound(res, req)
+		return
+	}
+	q := req.URL.Query()
+	switch {
+	case req.Method == "GET" && key == "" && q.Get("list-type") == "2":
+		s.list(res, q)
+	case req.Method == "GET" || req.Method == "HEAD":
+		obj := s.objects[key]
+		if obj == nil {
+			http.NotFound(res, req)
+			return
+		}
+		http.ServeContent(res, req, key, obj.modTime, bytes.NewReader(obj.data))
+	case req.Method == "PUT" && q.Has("uploadId"):
+		http.Error(res, "not implemented", http.StatusNotImplemented)
+	case req.Method == "PUT":
+		data, _ := io.ReadAll(req.Body)
+		if src := req.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			obj := s.objects[strings.TrimPrefix(src, "/"+s.bucket+"/")]
+			if obj == nil {
+				http.NotFound(res, req)
+				return
+			}
+			data = obj.data
+		}
+		s.objects[key] = &fakeS3Object{data: data, modTime: time.Now()}
+	case req.Method == "DELETE":
+		delete(s.objects, key)
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(res, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func (s *fakeS3) list(res http.ResponseWriter, q url.Values) {
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var result s3ListResult
+	start, _ := strconv.Atoi(q.Get("continuation-token"))
+	maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+	seen := map[string]bool{}
+	n := 0
+	for i := start; i < len(keys); i++ {
+		if maxKeys > 0 && n >= maxKeys {
+			result.IsTruncated, result.NextContinuationToken = true, strconv.Itoa(i)
+			break
+		}
+		k := keys[i]
+		if j := strings.Index(k[len(prefix):], delimiter); delimiter != "" && j >= 0 {
+			p := k[:len(prefix)+j+1]
+			if !seen[p] {
+				seen[p] = true
+				n++
+				result.CommonPrefixes = append(result.CommonPrefixes, struct{ Prefix string }{p})
+			}
+			continue
+		}
+		n++
+		result.Contents = append(result.Contents, struct {
+			Key          string
+			Size         int64
+			LastModified time.Time
+		}{k, int64(len(s.objects[k].data)), s.objects[k].modTime})
+	}
+	b, _ := xml.Marshal(&result)
+	res.Header().Set("Content-Type", "application/xml")
+	res.Write(b)
+}
+
+func TestS3FS(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{bucket: "test", objects: map[string]*fakeS3Object{}})
+	defer server.Close()
+	v, err := NewS3FS(&S3Config{Endpoint: server.URL, Bucket: "test", Prefix: "root", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkVolume(v, "."); err != nil {
+		t.Error(err)
+	}
+
+	if err := v.Mkdir("dir", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Rename("dir", "dir/sub"); err == nil {
+		t.Error("Rename: succeeded to move a directory into itself")
+	}
+	if _, err := v.Stat("dir"); err != nil {
+		t.Error(err)
+	}
+}
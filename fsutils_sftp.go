@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type SFTPConfig struct {
+	Host     string `json:"host"` // host:port
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// KeyPassphrase decrypts KeyFile if it is encrypted.
+	KeyPassphrase string `json:"keyPassphrase,omitempty"`
+	UseAgent      bool   `json:"useAgent,omitempty"`
+	// KnownHostsFile defaults to ~/.ssh/known_hosts. HostKeyFingerprint (SHA256:...) is checked instead if set.
+	KnownHostsFile     string `json:"knownHostsFile,omitempty"`
+	HostKeyFingerprint string `json:"hostKeyFingerprint,omitempty"`
+	// Root is the remote directory shown as the root of the volume.
+	Root string `json:"root,omitempty"`
+}
+
+var ErrNoAuthMethod = errors.New("no ssh auth method")
+
+// sftpFS is a Volume on a remote host.
+type sftpFS struct {
+	client *sftp.Client
+	conn   *ssh.Client
+	root   string
+}
+
+func NewSFTPFS(conf *SFTPConfig) (*sftpFS, error) {
+	auth, agentConn, err := conf.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	if agentConn != nil {
+		// The agent is used only while authenticating.
+		defer agentConn.Close()
+	}
+	hostKeyCallback, err := conf.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	host := conf.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	conn, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            conf.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	fsys := NewSFTPFSWithClient(client, conf.Root)
+	fsys.conn = conn
+	return fsys, nil
+}
+
+// NewSFTPFSWithClient creates a Volume using an established sftp session.
+func NewSFTPFSWithClient(client *sftp.Client, root string) *sftpFS {
+	if root == "" {
+		root = "."
+	}
+	return &sftpFS{client: client, root: root}
+}
+
+// authMethods returns the auth methods of conf and the connection to ssh-agent if it is used.
+func (conf *SFTPConfig) authMethods() ([]ssh.AuthMethod, net.Conn, error) {
+	var methods []ssh.AuthMethod
+	var agentConn net.Conn
+	if conf.KeyFile != "" {
+		key, err := os.ReadFile(conf.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		var signer ssh.Signer
+		if conf.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(conf.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if conf.UseAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if c, err := net.Dial("unix", sock); err == nil {
+				agentConn = c
+				methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(c).Signers))
+			}
+		}
+	}
+	if conf.Password != "" {
+		methods = append(methods, ssh.Password(conf.Password))
+	}
+	if len(methods) == 0 {
+		return nil, nil, ErrNoAuthMethod
+	}
+	return methods, agentConn, nil
+}
+
+func (conf *SFTPConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if conf.HostKeyFingerprint != "" {
+		want := []byte(conf.HostKeyFingerprint)
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if subtle.ConstantTimeCompare([]byte(ssh.FingerprintSHA256(key)), want) != 1 {
+				return errors.New("ssh: host key mismatch for " + hostname)
+			}
+			return nil
+		}, nil
+	}
+	file := conf.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return knownhosts.New(file)
+}
+
+func (fsys *sftpFS) Close() error {
+	err := fsys.client.Close()
+	if fsys.conn != nil {
+		fsys.conn.Close()
+	}
+	return err
+}
+
+func (fsys *sftpFS) remotePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(fsys.root, name), nil
+}
+
+func sftpError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (fsys *sftpFS) Open(name string) (fs.File, error) {
+	p, err := fsys.remotePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := fsys.client.Stat(p)
+	if err != nil {
+		return nil, sftpError("open", name, err)
+	}
+	if st.IsDir() {
+		return &sftpDir{fsys: fsys, path: p, info: st}, nil
+	}
+	f, err := fsys.client.Open(p)
+	if err != nil {
+		return nil, sftpError("open", name, err)
+	}
+	return f, nil
+}
+
+func (fsys *sftpFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := fsys.remotePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := fsys.client.Stat(p)
+	return st, sftpError("stat", name, err)
+}
+
+func (fsys *sftpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := fsys.remotePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	files, err := fsys.client.ReadDir(p)
+	if err != nil {
+		return nil, sftpError("readdir", name, err)
+	}
+	entries := make([]fs.DirEntry, len(files))
+	for i, f := range files {
+		entries[i] = fs.FileInfoToDirEntry(f)
+	}
+	return entries, nil
+}
+
+func (fsys *sftpFS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	p, err := fsys.remotePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.client.OpenFile(p, flag)
+	if err != nil {
+		return nil, sftpError("open", name, err)
+	}
+	if flag&os.O_APPEND != 0 {
+		// Some servers ignore SSH_FXF_APPEND.
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, sftpError("open", name, err)
+		}
+	}
+	return f, nil
+}
+
+func (fsys *sftpFS) Truncate(name string, size int64) error {
+	p, err := fsys.remotePath("truncate", name)
+	if err != nil {
+		return err
+	}
+	return sftpError("truncate", name, fsys.client.Truncate(p, size))
+}
+
+func (fsys *sftpFS) Remove(name string) error {
+	p, err := fsys.remotePath("remove", name)
+	if err != nil {
+		return err
+	}
+	return sftpError("remove", name, fsys.client.Remove(p))
+}
+
+// Mkdir ignores mode. The permissions are decided by the umask of the server.
+func (fsys *sftpFS) Mkdir(name string, mode fs.FileMode) error {
+	p, err := fsys.remotePath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return sftpError("mkdir", name, fsys.client.Mkdir(p))
+}
+
+// Rename replaces an existing newName if the server supports posix-rename@openssh.com.
+func (fsys *sftpFS) Rename(name, newName string) error {
+	p, err := fsys.remotePath("rename", name)
+	if err != nil {
+		return err
+	}
+	p2, err := fsys.remotePath("rename", newName)
+	if err != nil {
+		return err
+	}
+	if _, ok := fsys.client.HasExtension("posix-rename@openssh.com"); ok {
+		return sftpError("rename", name, fsys.client.PosixRename(p, p2))
+	}
+	return sftpError("rename", name, fsys.client.Rename(p, p2))
+}
+
+type sftpDir struct {
+	fsys    *sftpFS
+	path    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+func (d *sftpDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *sftpDir) Close() error               { return nil }
+func (d *sftpDir) Read([]byte) (int, error)   { return 0, ErrInvalidOp }
+
+func (d *sftpDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		files, err := d.fsys.client.ReadDir(d.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			d.entries = append(d.entries, fs.FileInfoToDirEntry(f))
+		}
+		d.loaded = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestSFTPFS(t *testing.T) {
+	dir := t.TempDir()
+	c1, c2 := net.Pipe()
+	server, err := sftp.NewServer(c1, sftp.WithServerWorkingDirectory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	defer server.Close()
+	client, err := sftp.NewClientPipe(c2, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewSFTPFSWithClient(client, filepath.ToSlash(dir))
+	defer v.Close()
+	if err := checkVolume(v, "."); err != nil {
+		t.Error(err)
+	}
+
+	// App.Mkdir passes 0666, which must not make directories unusable.
+	if err := v.Mkdir("sub", 0666); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Stat(filepath.Join(dir, "sub")); err != nil || st.Mode().Perm()&0100 == 0 {
+		t.Error("directory can not be entered", st, err)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WebDAVConfig struct {
+	URL      string `json:"url"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// webdavResponseTimeout limits waiting for the response headers. Bodies of large files are read without a deadline.
+const webdavResponseTimeout = 30 * time.Second
+
+// webdavFS is a Volume on a WebDAV server.
+type webdavFS struct {
+	base     *url.URL
+	user     string
+	password string
+	client   *http.Client
+	caps     Capability
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href     string        `xml:"DAV: href"`
+	Propstat []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string `xml:"DAV: status"`
+	Prop   struct {
+		ResourceType struct {
+			Collection *struct{} `xml:"DAV: collection"`
+		} `xml:"DAV: resourcetype"`
+		ContentLength string `xml:"DAV: getcontentlength"`
+		LastModified  string `xml:"DAV: getlastmodified"`
+	} `xml:"DAV: prop"`
+}
+
+func NewWebDAVFS(conf *WebDAVConfig) (*webdavFS, error) {
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = webdavResponseTimeout
+	fsys := &webdavFS{base: u, user: conf.User, password: conf.Password, client: &http.Client{Transport: transport}}
+	fsys.caps, err = fsys.detectCaps()
+	if err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
+
+// detectCaps checks the methods allowed by the server with OPTIONS.
+// Allow lists the methods for the root collection itself, so any write method means the volume is writable.
+func (fsys *webdavFS) detectCaps() (Capability, error) {
+	res, err := fsys.do("OPTIONS", ".", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return 0, httpStatusError(res)
+	}
+	allow := map[string]bool{}
+	for _, h := range res.Header.Values("Allow") {
+		for _, m := range strings.Split(h, ",") {
+			allow[strings.ToUpper(strings.TrimSpace(m))] = true
+		}
+	}
+	if allow["PUT"] || allow["MKCOL"] || allow["DELETE"] || allow["MOVE"] {
+		return Read | Stat | Write | Create | Mkdir | Remove | Rename, nil
+	}
+	return Read | Stat, nil
+}
+
+func (fsys *webdavFS) Caps() Capability {
+	return fsys.caps
+}
+
+func (fsys *webdavFS) url(name string) string {
+	u := *fsys.base
+	if name != "." {
+		u.Path += "/" + name
+		u.RawPath = fsys.base.EscapedPath() + "/" + escapePath(name)
+	}
+	return u.String()
+}
+
+func (fsys *webdavFS) do(method, name string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, fsys.url(name), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if fsys.user != "" || fsys.password != "" {
+		req.SetBasicAuth(fsys.user, fsys.password)
+	}
+	return fsys.client.Do(req)
+}
+
+func (fsys *webdavFS) propfind(name string, depth string) ([]davResponse, error) {
+	res, err := fsys.do("PROPFIND", name, strings.NewReader(propfindBody),
+		http.Header{"Depth": {depth}, "Content-Type": {"application/xml; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, httpStatusError(res)
+	}
+	var ms davMultistatus
+	if err := xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+	return ms.Responses, nil
+}
+
+func (r *davResponse) fileInfo() (*httpFileInfo, string) {
+	href := r.Href
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	href = strings.TrimSuffix(href, "/")
+	info := &httpFileInfo{name: path.Base(href)}
+	for _, ps := range r.Propstat {
+		if !strings.Contains(ps.Status, " 200 ") {
+			continue
+		}
+		info.isDir = info.isDir || ps.Prop.ResourceType.Collection != nil
+		if ps.Prop.ContentLength != "" {
+			info.size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+		}
+		if ps.Prop.LastModified != "" {
+			info.modTime, _ = http.ParseTime(ps.Prop.LastModified)
+		}
+	}
+	return info, href
+}
+
+func (fsys *webdavFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return fsys.stat(name)
+}
+
+func (fsys *webdavFS) stat(name string) (*httpFileInfo, error) {
+	responses, err := fsys.propfind(name, "0")
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if len(responses) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	info, _ := responses[0].fileInfo()
+	info.name = path.Base(name)
+	return info, nil
+}
+
+func (fsys *webdavFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	responses, err := fsys.propfind(name, "1")
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	self := strings.TrimSuffix(fsys.base.Path, "/")
+	if name != "." {
+		self += "/" + name
+	}
+	entries := []fs.DirEntry{}
+	for _, r := range responses {
+		info, href := r.fileInfo()
+		if href == self || href == "" {
+			continue
+		}
+		entries = append(entries, info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (fsys *webdavFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.isDir {
+		return &httpDir{info: info, list: func() ([]fs.DirEntry, error) { return fsys.ReadDir(name) }}, nil
+	}
+	return &httpRangeFile{info: info, open: func(offset, end int64) (*http.Response, error) {
+		res, err := fsys.do("GET", name, nil, http.Header{"Range": {rangeHeader(offset, end)}})
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+			res.Body.Close()
+			return nil, &fs.PathError{Op: "read", Path: name, Err: httpStatusError(res)}
+		}
+		return res, nil
+	}}, nil
+}
+
+// OpenWriter uploads the written data with PUT when closed.
+// WebDAV has no partial update, so the content is always replaced and O_APPEND is not supported.
+func (fsys *webdavFS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." || flag&os.O_APPEND != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fsys.stat(name)
+	if err == nil && (info.isDir || flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	if err != nil && flag&os.O_CREATE == 0 {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := &pipeUploadWriter{PipeWriter: pw, done: make(chan error, 1)}
+	go func() {
+		res, err := fsys.do("PUT", name, pr, nil)
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode >= 300 {
+				err = &fs.PathError{Op: "write", Path: name, Err: httpStatusError(res)}
+			}
+		}
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// pipeUploadWriter streams writes to a request body and waits for the response on Close.
+type pipeUploadWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *pipeUploadWriter) Close() error {
+	w.PipeWriter.Close()
+	return <-w.done
+}
+
+func (fsys *webdavFS) Truncate(name string, size int64) error {
+	return &fs.PathError{Op: "truncate", Path: name, Err: ErrInvalidOp}
+}
+
+func (fsys *webdavFS) Mkdir(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	res, err := fsys.do("MKCOL", name, nil, nil)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusMethodNotAllowed {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if res.StatusCode >= 300 {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: httpStatusError(res)}
+	}
+	return nil
+}
+
+// Remove deletes a file or an empty collection. DELETE on a collection is recursive in WebDAV,
+// so emptiness is checked first.
+func (fsys *webdavFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := fsys.checkEmptyDir("remove", name); err != nil {
+		return err
+	}
+	res, err := fsys.do("DELETE", name, nil, nil)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return &fs.PathError{Op: "remove", Path: name, Err: httpStatusError(res)}
+	}
+	return nil
+}
+
+func (fsys *webdavFS) checkEmptyDir(op, name string) error {
+	info, err := fsys.stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.isDir {
+		return nil
+	}
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return &fs.PathError{Op: op, Path: name, Err: ErrNotEmpty}
+	}
+	return nil
+}
+
+// Rename replaces an existing file or empty collection at newName like rename(2).
+func (fsys *webdavFS) Rename(name, newName string) error {
+	if !fs.ValidPath(name) || !fs.ValidPath(newName) || name == "." || newName == "." {
+		return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrInvalid}
+	}
+	src, err := fsys.stat(name)
+	if err != nil {
+		return err
+	}
+	if dst, err := fsys.stat(newName); err == nil {
+		if dst.isDir != src.isDir {
+			return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+		}
+		if err := fsys.checkEmptyDir("rename", newName); err != nil {
+			return err
+		}
+	}
+	res, err := fsys.do("MOVE", name, nil, http.Header{"Destination": {fsys.url(newName)}, "Overwrite": {"T"}})
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: name, Err: err}
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return &fs.PathError{Op: "rename", Path: name, Err: httpStatusError(res)}
+	}
+	return nil
+}
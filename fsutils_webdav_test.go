@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestWebDAVFS(t *testing.T) {
+	server := httptest.NewServer(&webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()})
+	defer server.Close()
+	v, err := NewWebDAVFS(&WebDAVConfig{URL: server.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Caps(v)&Write == 0 {
+		t.Error("WebDAV volume is not writable")
+	}
+	if err := checkVolume(v, "."); err != nil {
+		t.Error(err)
+	}
+}
@@ -91,6 +91,11 @@ func (r *RootFs) ResolveFS(name string) (*writableDirFS, string) {
 	return fs, name
 }
 
+func (r *RootFs) RealPath(name string) string {
+	fsys, name := r.ResolveFS(name)
+	return fsys.RealPath(name)
+}
+
 func (r *RootFs) Open(name string) (fs.File, error) {
 	fsys, name := r.ResolveFS(name)
 	return fsys.Open(name)
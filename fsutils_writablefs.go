@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 type BasicFS interface {
@@ -22,6 +23,17 @@ func NewWritableDirFS(path string) *writableDirFS {
 	return &writableDirFS{BasicFS: os.DirFS(path).(BasicFS), path: path}
 }
 
+func (fsys *writableDirFS) RealPath(name string) string {
+	return filepath.Join(fsys.path, filepath.FromSlash(name))
+}
+
+func (fsys *writableDirFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.Lstat(path.Join(fsys.path, name))
+}
+
 func (fsys *writableDirFS) OpenWriter(name string, flag int) (io.WriteCloser, error) {
 	if !fs.ValidPath(name) {
 		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
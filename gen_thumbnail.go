@@ -29,6 +29,14 @@ import (
 type ThumbnailConfig struct {
 	CacheDir   string
 	FFmpegPath string `toml:"ffmpegPath"`
+
+	AnimatedFrames   int   `toml:"animatedFrames"`
+	AnimatedMaxBytes int64 `toml:"animatedMaxBytes"`
+}
+
+func NewThumbnailConfig(cacheDir string) *ThumbnailConfig {
+	ffmpegPath, _ := exec.LookPath("ffmpeg")
+	return &ThumbnailConfig{CacheDir: cacheDir, FFmpegPath: ffmpegPath}
 }
 
 var thumbnailTaskDispatcher = NewDispatcher(8, 16, true)
@@ -39,31 +47,57 @@ func hash(s string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func RequestThumbnail(v Volume, srcType, srcPath, cacheID string, conf *ThumbnailConfig) chan string {
-	result := make(chan string, 1)
-	once := sync.Once{}
-	defer once.Do(func() { close(result) })
+// ThumbnailSourceType returns the srcType for RequestThumbnail, or "" if the type has no thumbnail.
+func ThumbnailSourceType(mimeType string) string {
+	if IsTextMimeType(mimeType) {
+		return "text"
+	}
+	if mimeType == "image/svg+xml" {
+		return "svg"
+	}
+	if mimeType == "archive" {
+		return "archive"
+	}
+	if t := ParseMimeType(mimeType); len(t) > 0 && (t[0] == "image" || t[0] == "video") {
+		return t[0]
+	}
+	return ""
+}
 
+func RequestThumbnail(v Volume, srcType, srcPath, cacheID string, conf *ThumbnailConfig) chan string {
 	if cacheID == "" {
 		cacheID = hash(srcPath)
 	}
+	cachePath := path.Join(conf.CacheDir, cacheID+".jpeg")
+	if srcType != "image" && srcType != "video" && srcType != "archive" && srcType != "text" && srcType != "svg" {
+		srcType = ""
+	}
+	return requestCachedFile(cachePath, srcType != "", func(ctx context.Context) error {
+		return MakeThumbnail(ctx, v, srcType, srcPath, cachePath, conf)
+	})
+}
 
-	os.MkdirAll(conf.CacheDir, os.ModePerm)
+// requestCachedFile returns cachePath if it exists, otherwise generates it with makeFn in thumbnailTaskDispatcher.
+func requestCachedFile(cachePath string, canMake bool, makeFn func(ctx context.Context) error) chan string {
+	result := make(chan string, 1)
+	once := sync.Once{}
+	defer once.Do(func() { close(result) })
+
+	os.MkdirAll(path.Dir(cachePath), os.ModePerm)
 
-	cachePath := path.Join(conf.CacheDir, cacheID+".jpeg")
 	if _, err := os.Stat(cachePath); err == nil {
 		result <- cachePath
 		return result
 	}
 
-	if srcType != "image" && srcType != "video" && srcType != "archive" {
+	if !canMake {
 		return result
 	}
 
 	taskFun := func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10000*time.Millisecond)
 		defer cancel()
-		err := MakeThumbnail(ctx, v, srcType, srcPath, cachePath, conf)
+		err := makeFn(ctx)
 		if err != nil {
 			log.Println("Failed to generate thumbnail ", err)
 		}
@@ -87,28 +121,33 @@ func RequestThumbnail(v Volume, srcType, srcPath, cacheID string, conf *Thumbnai
 func MakeThumbnail(ctx context.Context, v Volume, srcType, srcPath, cachePath string, conf *ThumbnailConfig) error {
 	if v == nil {
 		if srcType == "video" {
-			return makeVideoThumbnail(ctx, srcPath, cachePath, conf)
+			return makeVideoThumbnail(ctx, srcPath, nil, cachePath, conf)
 		}
 		return errors.New("not supporetd volume type")
 	}
 
 	log.Println("Generating thumbnail... ", srcPath)
-	if srcType == "video" {
-		if rv, ok := v.(interface{ RealPath(string) string }); ok {
-			return makeVideoThumbnail(ctx, rv.RealPath(srcPath), cachePath, conf)
-		}
-	} else {
-		in, err := v.Open(srcPath)
-		if err != nil {
-			return err
-		}
-		defer in.Close()
-		return makeImageThumbnail(ctx, in, cachePath)
+	if src, ok := MediaSource(v, srcPath); ok && srcType == "video" {
+		return makeVideoThumbnail(ctx, src, nil, cachePath, conf)
 	}
-	return errors.New("not supporetd volume type")
+	in, err := v.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	switch srcType {
+	case "video":
+		return makeVideoThumbnail(ctx, "pipe:0", in, cachePath, conf)
+	case "text":
+		return makeTextThumbnail(ctx, in, cachePath)
+	case "svg":
+		return makeSvgThumbnail(ctx, in, cachePath)
+	}
+	return makeImageThumbnail(ctx, in, cachePath)
 }
 
-func makeVideoThumbnail(ctx context.Context, in, out string, conf *ThumbnailConfig) error {
+// makeVideoThumbnail reads the video from stdin if it is not nil. in is "pipe:0" in that case.
+func makeVideoThumbnail(ctx context.Context, in string, stdin io.Reader, out string, conf *ThumbnailConfig) error {
 	if conf.FFmpegPath == "" {
 		log.Println("MakeVideoThumbnail: FFmpegPath is not configured")
 		return errors.New("MakeVideoThumbnail: conf.FFmpegPath")
@@ -127,16 +166,17 @@ func makeVideoThumbnail(ctx context.Context, in, out string, conf *ThumbnailConf
 		}
 	}
 	c := exec.CommandContext(ctx, conf.FFmpegPath, args...)
+	c.Stdin = stdin
 	err := c.Start()
 	if err != nil {
-		log.Println(conf.FFmpegPath, args)
+		// The arguments are not logged because URLs may contain secrets.
+		log.Println(conf.FFmpegPath, err)
 		return nil
 	}
 	err = c.Wait()
 	_, err2 := os.Stat(out)
-	if err == nil && err2 != nil {
-		log.Println("RETRY ", conf.FFmpegPath, "-i", in, "-vframes", "1",
-			"-vcodec", "mjpeg", "-an", "-vf", "scale=200:-1", out)
+	if err == nil && err2 != nil && stdin == nil {
+		log.Println("RETRY ", conf.FFmpegPath, "without -ss")
 		// TODO
 		c := exec.CommandContext(ctx, conf.FFmpegPath, "-i", in, "-vframes", "1",
 			"-vcodec", "mjpeg", "-an", "-vf", "scale=200:-1", out)
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	animatedThumbnailWidth    = 160
+	animatedThumbnailDuration = 3 // seconds of video
+	animatedThumbnailFPS      = 8
+	defaultAnimatedFrames     = 24
+	defaultAnimatedMaxBytes   = 1024 * 1024
+	animatedSourceMaxBytes    = 32 * 1024 * 1024
+)
+
+var errAnimatedTooLarge = errors.New("animated thumbnail is too large")
+
+func (c *ThumbnailConfig) animatedLimits() (int, int64) {
+	frames, maxBytes := c.AnimatedFrames, c.AnimatedMaxBytes
+	if frames <= 0 {
+		frames = defaultAnimatedFrames
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultAnimatedMaxBytes
+	}
+	return frames, maxBytes
+}
+
+// RequestAnimatedThumbnail generates a small animated GIF preview of a GIF image or a video clip.
+func RequestAnimatedThumbnail(v Volume, srcType, srcPath, cacheID string, conf *ThumbnailConfig) chan string {
+	if cacheID == "" {
+		cacheID = hash(srcPath)
+	}
+	cachePath := path.Join(conf.CacheDir, cacheID+".anim.gif")
+	canMake := srcType == "video" || srcType == "image" && MimeTypeByFilename(srcPath) == "image/gif"
+	return requestCachedFile(cachePath, canMake, func(ctx context.Context) error {
+		return MakeAnimatedThumbnail(ctx, v, srcType, srcPath, cachePath, conf)
+	})
+}
+
+func MakeAnimatedThumbnail(ctx context.Context, v Volume, srcType, srcPath, cachePath string, conf *ThumbnailConfig) error {
+	if src, ok := MediaSource(v, srcPath); ok && srcType == "video" {
+		return makeAnimatedVideoThumbnail(ctx, src, nil, cachePath, conf)
+	}
+
+	in, err := v.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if srcType == "video" {
+		return makeAnimatedVideoThumbnail(ctx, "pipe:0", in, cachePath, conf)
+	}
+	return makeAnimatedGifThumbnail(ctx, in, cachePath, conf)
+}
+
+func makeAnimatedGifThumbnail(ctx context.Context, in io.Reader, out string, conf *ThumbnailConfig) error {
+	maxFrames, maxBytes := conf.animatedLimits()
+	data, err := io.ReadAll(io.LimitReader(in, animatedSourceMaxBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > animatedSourceMaxBytes {
+		return errAnimatedTooLarge
+	}
+	// Frames are composed on a canvas of the logical screen size.
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if int64(config.Width)*int64(config.Height) > maxDecodeImagePixels {
+		return ErrImageTooLarge
+	}
+	src, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	step := (len(src.Image) + maxFrames - 1) / maxFrames
+	dst := &gif.GIF{LoopCount: 0}
+	err = composeGifFrames(src, step, func(i int, frame image.Image) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		delay := 0
+		for j := i; j < i+step && j < len(src.Delay); j++ {
+			delay += src.Delay[j]
+		}
+		dst.Image = append(dst.Image, toPaletted(resize.Resize(animatedThumbnailWidth, 0, frame, resize.Bilinear)))
+		dst.Delay = append(dst.Delay, delay)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, dst); err != nil {
+		return err
+	}
+	if int64(buf.Len()) > maxBytes {
+		return errAnimatedTooLarge
+	}
+	return os.WriteFile(out, buf.Bytes(), 0644)
+}
+
+// composeGifFrames renders each frame over the previous ones, since GIF frames are often partial,
+// and calls fn with every step-th rendered frame. The frame passed to fn is reused after fn returns.
+func composeGifFrames(g *gif.GIF, step int, fn func(i int, frame image.Image) error) error {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	for i, frame := range g.Image {
+		var prev *image.RGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			prev = image.NewRGBA(bounds)
+			copy(prev.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if i%step == 0 {
+			if err := fn(i, canvas); err != nil {
+				return err
+			}
+		}
+
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+			case gif.DisposalPrevious:
+				canvas = prev
+			}
+		}
+	}
+	return nil
+}
+
+func toPaletted(img image.Image) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(p, img.Bounds(), img, img.Bounds().Min)
+	return p
+}
+
+// makeAnimatedVideoThumbnail reads the video from stdin if it is not nil. in is "pipe:0" in that case.
+func makeAnimatedVideoThumbnail(ctx context.Context, in string, stdin io.Reader, out string, conf *ThumbnailConfig) error {
+	if conf.FFmpegPath == "" {
+		return errors.New("MakeAnimatedThumbnail: conf.FFmpegPath")
+	}
+	maxFrames, maxBytes := conf.animatedLimits()
+	filter := fmt.Sprintf("fps=%d,scale=%d:-1:flags=lanczos,split[a][b];[a]palettegen[p];[b][p]paletteuse",
+		animatedThumbnailFPS, animatedThumbnailWidth)
+	tmp := out + ".tmp.gif"
+	defer os.Remove(tmp)
+	args := []string{"-nostdin", "-y", "-ss", "3",
+		"-t", fmt.Sprint(animatedThumbnailDuration), "-i", in,
+		"-frames:v", fmt.Sprint(maxFrames), "-an", "-filter_complex", filter, "-loop", "0", tmp}
+	if stdin != nil {
+		args = args[1:]
+	}
+	c := exec.CommandContext(ctx, conf.FFmpegPath, args...)
+	c.Stdin = stdin
+	if err := c.Run(); err != nil {
+		return err
+	}
+	stat, err := os.Stat(tmp)
+	if err != nil {
+		return err
+	}
+	if stat.Size() > maxBytes {
+		return errAnimatedTooLarge
+	}
+	return os.Rename(tmp, out)
+}
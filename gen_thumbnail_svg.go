@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+const (
+	svgThumbnailWidth     = 160
+	svgThumbnailMaxHeight = svgThumbnailWidth * 4
+	svgMaxFileSize        = 4 * 1024 * 1024
+	svgMaxPathCount       = 100000
+)
+
+var errSvgTooLarge = errors.New("svg is too large")
+
+// makeSvgThumbnail rasterizes svg without loading any external resources.
+// oksvg ignores <image> and external references, so only inline shapes are drawn.
+func makeSvgThumbnail(_ context.Context, in io.Reader, out string) error {
+	data, err := io.ReadAll(io.LimitReader(in, svgMaxFileSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > svgMaxFileSize {
+		return errSvgTooLarge
+	}
+
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return err
+	}
+	if len(icon.SVGPaths) > svgMaxPathCount {
+		return errSvgTooLarge
+	}
+
+	vw, vh := icon.ViewBox.W, icon.ViewBox.H
+	if vw <= 0 || vh <= 0 {
+		return errors.New("svg has no size")
+	}
+	w := svgThumbnailWidth
+	h := int(float64(w) * vh / vw)
+	if h < 1 {
+		h = 1
+	} else if h > svgThumbnailMaxHeight {
+		h = svgThumbnailMaxHeight
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	icon.SetTarget(0, 0, float64(w), float64(h))
+	icon.Draw(rasterx.NewDasher(w, h, rasterx.NewScannerGV(w, h, img, img.Bounds())), 1)
+
+	thumb, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer thumb.Close()
+	return jpeg.Encode(thumb, img, nil)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+const (
+	textThumbnailSize     = 160
+	textThumbnailMargin   = 4
+	textThumbnailReadSize = 4096
+	textThumbnailTabWidth = 4
+)
+
+var errBinaryContent = errors.New("binary content")
+
+var textThumbnailFallbackEncodings = []encoding.Encoding{
+	japanese.ShiftJIS,
+	japanese.EUCJP,
+}
+
+// DecodeText converts the beginning of a text file to UTF-8.
+// BOMs are honored, otherwise UTF-8 is preferred and legacy encodings are tried in order.
+func DecodeText(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xef, 0xbb, 0xbf}):
+		return string(b[3:])
+	case bytes.HasPrefix(b, []byte{0xff, 0xfe}):
+		return decodeWith(xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM), b)
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		return decodeWith(xunicode.UTF16(xunicode.BigEndian, xunicode.UseBOM), b)
+	}
+
+	if validUTF8Prefix(b) {
+		return string(b)
+	}
+	for _, enc := range textThumbnailFallbackEncodings {
+		if s := decodeWith(enc, b); !strings.ContainsRune(s, utf8.RuneError) {
+			return s
+		}
+	}
+	return decodeWith(charmap.Windows1252, b)
+}
+
+// validUTF8Prefix ignores a multi-byte sequence cut off at the end of a partial read.
+func validUTF8Prefix(b []byte) bool {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				b = b[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(b)
+}
+
+func decodeWith(enc encoding.Encoding, b []byte) string {
+	s, _ := enc.NewDecoder().Bytes(b)
+	return string(s)
+}
+
+func looksLikeBinary(b []byte) bool {
+	if bytes.HasPrefix(b, []byte{0xff, 0xfe}) || bytes.HasPrefix(b, []byte{0xfe, 0xff}) {
+		return false
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+func makeTextThumbnail(_ context.Context, in io.Reader, out string) error {
+	buf := make([]byte, textThumbnailReadSize)
+	n, err := io.ReadFull(in, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	buf = buf[:n]
+	if looksLikeBinary(buf) {
+		return errBinaryContent
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, textThumbnailSize, textThumbnailSize))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(color.Gray{Y: 0x30}), Face: face}
+	lineHeight := face.Height
+	cols := (textThumbnailSize - textThumbnailMargin*2) / face.Advance
+	y := textThumbnailMargin + face.Ascent
+
+	for _, line := range strings.Split(DecodeText(buf), "\n") {
+		if y+face.Descent > textThumbnailSize-textThumbnailMargin {
+			break
+		}
+		d.Dot = fixed.P(textThumbnailMargin, y)
+		d.DrawString(printableLine(line, cols))
+		y += lineHeight
+	}
+
+	thumb, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer thumb.Close()
+	return jpeg.Encode(thumb, img, &jpeg.Options{Quality: 85})
+}
+
+func printableLine(line string, cols int) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range strings.TrimRight(line, "\r") {
+		if n >= cols {
+			break
+		}
+		if r == '\t' {
+			w := textThumbnailTabWidth - n%textThumbnailTabWidth
+			sb.WriteString(strings.Repeat(" ", w))
+			n += w
+			continue
+		}
+		if !unicode.IsPrint(r) {
+			r = '.'
+		}
+		sb.WriteRune(r)
+		n++
+	}
+	return sb.String()
+}
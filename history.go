@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxHistory = 100
+
+var ErrStateChanged = errors.New("file was changed after the operation")
+
+// Operation is a reversible file operation recorded in History.
+type Operation struct {
+	ID     int              `json:"id"`
+	Kind   string           `json:"kind"` // "rename", "bulkrename", "move", "mkdir", "trash" or "copy"
+	Items  []*OperationItem `json:"items"`
+	Time   int64            `json:"time"`
+	Undone bool             `json:"undone"`
+}
+
+// OperationItem is a file changed by an Operation. Src is empty for "mkdir" and Dst is empty for "trash".
+type OperationItem struct {
+	Src      string `json:"src,omitempty"`
+	Dst      string `json:"dst,omitempty"`
+	realPath string // local path of a trashed file, to find it in the trash
+	isDir    bool
+	size     int64
+	modTime  time.Time
+}
+
+// NewOperationItem returns an item of a file moved from src to dst. The file at dst is compared with the current one on Undo.
+func NewOperationItem(v Volume, src, dst string) *OperationItem {
+	item := &OperationItem{Src: src, Dst: dst}
+	if dst != "" {
+		item.record(v, dst)
+	}
+	return item
+}
+
+func (item *OperationItem) record(v Volume, name string) {
+	if st, err := v.Stat(name); err == nil {
+		item.isDir, item.size, item.modTime = st.IsDir(), st.Size(), st.ModTime()
+	}
+}
+
+// History is the undo/redo log of the operations of a user.
+type History struct {
+	mutex  sync.Mutex
+	done   []*Operation
+	undone []*Operation
+	seq    int
+}
+
+func NewHistory() *History {
+	return &History{}
+}
+
+// Add records an operation of kind with the changed files. The undone operations can not be redone after this.
+func (h *History) Add(kind string, items ...*OperationItem) {
+	if len(items) == 0 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.seq++
+	h.done = append(h.done, &Operation{ID: h.seq, Kind: kind, Items: items, Time: time.Now().UnixMilli()})
+	if len(h.done) > maxHistory {
+		h.done = h.done[len(h.done)-maxHistory:]
+	}
+	h.undone = nil
+}
+
+// Recent returns the operations from the latest, followed by the undone operations which can be redone.
+func (h *History) Recent() []*Operation {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ops := []*Operation{}
+	for i := len(h.undone) - 1; i >= 0; i-- {
+		ops = append(ops, h.undone[i])
+	}
+	for i := len(h.done) - 1; i >= 0; i-- {
+		ops = append(ops, h.done[i])
+	}
+	return ops
+}
+
+// Undo reverses the latest operation if the files are not changed after it.
+func (h *History) Undo(v Volume) (*Operation, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.done) == 0 {
+		return nil, nil
+	}
+	op := h.done[len(h.done)-1]
+	if err := op.apply(v, false); err != nil {
+		return nil, err
+	}
+	op.Undone = true
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, op)
+	return op, nil
+}
+
+// Redo performs the latest undone operation again.
+func (h *History) Redo(v Volume) (*Operation, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.undone) == 0 {
+		return nil, nil
+	}
+	op := h.undone[len(h.undone)-1]
+	if err := op.apply(v, true); err != nil {
+		return nil, err
+	}
+	op.Undone = false
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, op)
+	return op, nil
+}
+
+// apply checks all items before changing files. If an item fails, the items already changed are reverted.
+func (op *Operation) apply(v Volume, forward bool) error {
+	for _, item := range op.Items {
+		if err := op.check(v, item, forward); err != nil {
+			return err
+		}
+	}
+	if op.Kind == "bulkrename" {
+		return op.applyBulkRename(v, forward)
+	}
+	for i, item := range op.Items {
+		if err := op.applyItem(v, item, forward); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				op.applyItem(v, op.Items[j], !forward)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// applyBulkRename renames all items at once, since they may take the names of each other (e.g. "a" <-> "b").
+func (op *Operation) applyBulkRename(v Volume, forward bool) error {
+	from, to := make([]string, len(op.Items)), make([]string, len(op.Items))
+	for i, item := range op.Items {
+		from[i], to[i] = item.Dst, item.Src
+		if forward {
+			from[i], to[i] = item.Src, item.Dst
+		}
+	}
+	if err := renameAll(v, from, to); err != nil {
+		return err
+	}
+	for i, item := range op.Items {
+		item.record(v, to[i])
+	}
+	return nil
+}
+
+// renamesFrom reports whether a file of op is moved away from name.
+func (op *Operation) renamesFrom(name string, forward bool) bool {
+	for _, item := range op.Items {
+		if forward && item.Src == name || !forward && item.Dst == name {
+			return true
+		}
+	}
+	return false
+}
+
+func exists(v Volume, name string) bool {
+	_, err := v.Stat(name)
+	return err == nil
+}
+
+// matches reports whether name is the file recorded in item.
+// Folders are compared by the type only, except copied folders which are removed by Undo.
+func (op *Operation) matches(v Volume, item *OperationItem, name string) bool {
+	st, err := v.Stat(name)
+	if err != nil || st.IsDir() != item.isDir {
+		return false
+	}
+	if item.isDir && op.Kind != "copy" {
+		return true
+	}
+	return st.ModTime().Equal(item.modTime) && (item.isDir || st.Size() == item.size)
+}
+
+func (op *Operation) check(v Volume, item *OperationItem, forward bool) error {
+	name, ok := item.Dst, true
+	switch op.Kind {
+	case "rename", "move":
+		if forward {
+			name = item.Src
+			ok = op.matches(v, item, item.Src) && !exists(v, item.Dst)
+		} else {
+			ok = op.matches(v, item, item.Dst) && !exists(v, item.Src)
+		}
+	case "bulkrename":
+		from, to := item.Dst, item.Src
+		if forward {
+			from, to = item.Src, item.Dst
+		}
+		name = from
+		ok = op.matches(v, item, from) && (!exists(v, to) || op.renamesFrom(to, forward) || strings.EqualFold(from, to))
+	case "copy":
+		if forward {
+			ok = exists(v, item.Src) && !exists(v, item.Dst)
+		} else {
+			ok = op.matches(v, item, item.Dst)
+		}
+	case "mkdir":
+		if forward {
+			ok = !exists(v, item.Dst)
+		} else {
+			entries, err := fs.ReadDir(v, item.Dst)
+			ok = err == nil && len(entries) == 0
+		}
+	case "trash":
+		name = item.Src
+		if forward {
+			ok = exists(v, item.Src)
+		} else {
+			ok = !exists(v, item.Src) && CapsAt(v, item.Src)&Trash != 0
+		}
+	default:
+		return ErrInvalidOp
+	}
+	if !ok {
+		return &fs.PathError{Op: "undo", Path: name, Err: ErrStateChanged}
+	}
+	return nil
+}
+
+func (op *Operation) applyItem(v Volume, item *OperationItem, forward bool) error {
+	switch op.Kind {
+	case "rename", "move":
+		src, dst := item.Src, item.Dst
+		if !forward {
+			src, dst = dst, src
+		}
+		if err := MoveAll(v, src, dst); err != nil {
+			return err
+		}
+		item.record(v, dst)
+		return nil
+	case "copy":
+		if !forward {
+			return RemoveAll(context.Background(), v, item.Dst)
+		}
+		if err := CopyAll(v, item.Src, item.Dst); err != nil {
+			return err
+		}
+		item.record(v, item.Dst)
+		return nil
+	case "mkdir":
+		if forward {
+			return v.Mkdir(item.Dst, 0777)
+		}
+		return v.Remove(item.Dst)
+	case "trash":
+		if forward {
+			t, ok := v.(TrashFS)
+			if !ok {
+				return ErrInvalidOp
+			}
+			return t.MoveToTrash(item.Src)
+		}
+		return restoreTrashedFile(item.realPath)
+	}
+	return ErrInvalidOp
+}
+
+// restoreTrashedFile restores the latest item trashed from realPath.
+func restoreTrashedFile(realPath string) error {
+	items, err := ListTrash()
+	if err != nil {
+		return err
+	}
+	var found *TrashItem
+	for _, item := range items {
+		if item.OriginalPath == realPath && (found == nil || item.DeletionTime > found.DeletionTime) {
+			found = item
+		}
+	}
+	if found == nil {
+		return &fs.PathError{Op: "restore", Path: realPath, Err: fs.ErrNotExist}
+	}
+	return RestoreFromTrash(found.ID)
+}
+
+// Histories keeps a History for each user.
+type Histories struct {
+	mutex sync.Mutex
+	users map[string]*History
+}
+
+func NewHistories() *Histories {
+	return &Histories{users: map[string]*History{}}
+}
+
+func (hs *Histories) Get(user string) *History {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+	h := hs.users[user]
+	if h == nil {
+		h = NewHistory()
+		hs.users[user] = h
+	}
+	return h
+}
+
+func operationKind(src, dst string) string {
+	if path.Dir(src) == path.Dir(dst) {
+		return "rename"
+	}
+	return "move"
+}
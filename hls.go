@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	hlsPlaylistName    = "index.m3u8"
+	hlsSegmentDuration = "4"
+	hlsIdleTimeout     = 60 * time.Second
+	hlsWaitTimeout     = 15 * time.Second
+	hlsCacheExpire     = 24 * time.Hour
+)
+
+var (
+	ErrTranscoderBusy = errors.New("transcoder is busy")
+	hlsSegmentPattern = regexp.MustCompile(`^seg[0-9]{5}\.ts$`)
+)
+
+type hlsSession struct {
+	dir        string
+	cancel     context.CancelFunc
+	done       chan struct{}
+	lastAccess atomic.Int64
+	// started is set when the task starts, or by Stop to drop the task if it is still queued.
+	started atomic.Bool
+}
+
+func (s *hlsSession) touch() {
+	s.lastAccess.Store(time.Now().UnixMilli())
+}
+
+func (s *hlsSession) idle() time.Duration {
+	return time.Since(time.UnixMilli(s.lastAccess.Load()))
+}
+
+// HLSTranscoder generates HLS playlists and segments with ffmpeg on demand.
+// Transcoding stops when the player is closed or no segment is requested for a while.
+type HLSTranscoder struct {
+	conf       *ThumbnailConfig
+	dispatcher *Dispatcher
+	mutex      sync.Mutex
+	sessions   map[string]*hlsSession
+}
+
+func NewHLSTranscoder(conf *ThumbnailConfig, maxSessions int) *HLSTranscoder {
+	return &HLSTranscoder{
+		conf:       conf,
+		dispatcher: NewDispatcher(maxSessions, maxSessions, true),
+		sessions:   map[string]*hlsSession{},
+	}
+}
+
+func (t *HLSTranscoder) cacheDir(srcPath string) string {
+	return filepath.Join(t.conf.CacheDir, "hls", hash(srcPath))
+}
+
+// Playlist returns the local path of the playlist for srcPath, starting ffmpeg if needed.
+// Segment URIs in the playlist are prefixed with baseURL.
+func (t *HLSTranscoder) Playlist(v Volume, srcPath, baseURL string) (string, error) {
+	dir := t.cacheDir(srcPath)
+	playlist := filepath.Join(dir, hlsPlaylistName)
+	if isCompletePlaylist(playlist) {
+		return playlist, nil
+	}
+
+	s, err := t.startSession(v, srcPath, dir, baseURL)
+	if err != nil {
+		return "", err
+	}
+	deadline := time.After(hlsWaitTimeout)
+	for {
+		if _, err := os.Stat(playlist); err == nil {
+			return playlist, nil
+		}
+		select {
+		case <-s.done:
+			if _, err := os.Stat(playlist); err == nil {
+				return playlist, nil
+			}
+			return "", errors.New("transcoding failed")
+		case <-deadline:
+			return "", os.ErrDeadlineExceeded
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// Segment returns the local path of a generated segment.
+func (t *HLSTranscoder) Segment(srcPath, name string) (string, error) {
+	if !hlsSegmentPattern.MatchString(name) {
+		return "", os.ErrInvalid
+	}
+	t.mutex.Lock()
+	s := t.sessions[srcPath]
+	t.mutex.Unlock()
+	if s != nil {
+		s.touch()
+	}
+	p := filepath.Join(t.cacheDir(srcPath), name)
+	if _, err := os.Stat(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// Stop kills running ffmpeg for srcPath. Incomplete output is removed.
+func (t *HLSTranscoder) Stop(srcPath string) {
+	t.mutex.Lock()
+	s := t.sessions[srcPath]
+	t.mutex.Unlock()
+	if s == nil {
+		return
+	}
+	s.cancel()
+	if s.started.CompareAndSwap(false, true) {
+		t.finishSession(srcPath, s)
+		return
+	}
+	<-s.done
+}
+
+func (t *HLSTranscoder) startSession(v Volume, srcPath, dir, baseURL string) (*hlsSession, error) {
+	if t.conf.FFmpegPath == "" {
+		return nil, errors.New("FFmpegPath is not configured")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if s, ok := t.sessions[srcPath]; ok {
+		s.touch()
+		return s, nil
+	}
+
+	t.cleanupCache()
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &hlsSession{dir: dir, cancel: cancel, done: make(chan struct{})}
+	s.touch()
+	task := t.dispatcher.TryAddFunc(func() {
+		if !s.started.CompareAndSwap(false, true) {
+			return
+		}
+		defer t.finishSession(srcPath, s)
+		go t.watchIdle(ctx, s)
+		if err := t.transcode(ctx, v, srcPath, dir, baseURL); err != nil && ctx.Err() == nil {
+			log.Println("Failed to transcode ", srcPath, err)
+		}
+	}, "hls:"+srcPath)
+	if task == nil {
+		cancel()
+		return nil, ErrTranscoderBusy
+	}
+	t.sessions[srcPath] = s
+	return s, nil
+}
+
+func (t *HLSTranscoder) finishSession(srcPath string, s *hlsSession) {
+	s.cancel()
+	if !isCompletePlaylist(filepath.Join(s.dir, hlsPlaylistName)) {
+		os.RemoveAll(s.dir)
+	}
+	t.mutex.Lock()
+	if t.sessions[srcPath] == s {
+		delete(t.sessions, srcPath)
+	}
+	t.mutex.Unlock()
+	close(s.done)
+}
+
+func (t *HLSTranscoder) watchIdle(ctx context.Context, s *hlsSession) {
+	ticker := time.NewTicker(hlsIdleTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.idle() > hlsIdleTimeout {
+				s.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (t *HLSTranscoder) transcode(ctx context.Context, v Volume, srcPath, dir, baseURL string) error {
+	input := "pipe:0"
+	var stdin io.ReadCloser
+	if src, ok := MediaSource(v, srcPath); ok {
+		input = src
+	} else {
+		f, err := v.Open(srcPath)
+		if err != nil {
+			return err
+		}
+		stdin = f
+		defer f.Close()
+	}
+
+	args := []string{"-nostdin", "-i", input,
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-ac", "2",
+		"-f", "hls", "-hls_time", hlsSegmentDuration, "-hls_playlist_type", "event",
+		"-hls_segment_filename", filepath.Join(dir, "seg%05d.ts"),
+		"-hls_base_url", baseURL,
+		filepath.Join(dir, hlsPlaylistName)}
+	if stdin != nil {
+		args = args[1:]
+	}
+	c := exec.CommandContext(ctx, t.conf.FFmpegPath, args...)
+	if stdin != nil {
+		c.Stdin = stdin
+	}
+	return c.Run()
+}
+
+// cleanupCache removes expired transcodes. Must be called with t.mutex held.
+func (t *HLSTranscoder) cleanupCache() {
+	root := filepath.Join(t.conf.CacheDir, "hls")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < hlsCacheExpire {
+			continue
+		}
+		os.RemoveAll(filepath.Join(root, e.Name()))
+	}
+}
+
+func isCompletePlaylist(playlist string) bool {
+	b, err := os.ReadFile(playlist)
+	return err == nil && bytes.Contains(b, []byte("#EXT-X-ENDLIST"))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	_ "golang.org/x/image/tiff"
+
+	"github.com/nfnt/resize"
+)
+
+const maxConvertImageSize = 16384
+
+// maxDecodeImagePixels limits the memory to decode an image (about 4 bytes per pixel).
+const maxDecodeImagePixels = 128 * 1024 * 1024
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+var ErrImageTooLarge = errors.New("image is too large")
+
+type ImageConvertOptions struct {
+	MaxWidth  int
+	MaxHeight int
+	Format    string // "jpeg" or "png"
+	Quality   int
+}
+
+func NormalizeImageFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "jpeg", "jpg":
+		return "jpeg", nil
+	case "png":
+		return "png", nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+func ImageFormatMimeType(format string) string {
+	if format == "png" {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// ResizeToFit downscales img to fit in maxWidth x maxHeight keeping the aspect ratio.
+// Zero means no limit for the dimension.
+func ResizeToFit(img image.Image, maxWidth, maxHeight int) image.Image {
+	b := img.Bounds()
+	if maxWidth <= 0 || maxWidth > maxConvertImageSize {
+		maxWidth = min(b.Dx(), maxConvertImageSize)
+	}
+	if maxHeight <= 0 || maxHeight > maxConvertImageSize {
+		maxHeight = min(b.Dy(), maxConvertImageSize)
+	}
+	return resize.Thumbnail(uint(maxWidth), uint(maxHeight), img, resize.Lanczos3)
+}
+
+func ConvertImage(in io.Reader, out io.Writer, opt *ImageConvertOptions) error {
+	format, err := NormalizeImageFormat(opt.Format)
+	if err != nil {
+		return err
+	}
+	var header bytes.Buffer
+	conf, _, err := image.DecodeConfig(io.TeeReader(in, &header))
+	if err != nil {
+		return err
+	}
+	if int64(conf.Width)*int64(conf.Height) > maxDecodeImagePixels {
+		return ErrImageTooLarge
+	}
+	img, _, err := image.Decode(io.MultiReader(&header, in))
+	if err != nil {
+		return err
+	}
+	img = ResizeToFit(img, opt.MaxWidth, opt.MaxHeight)
+
+	if format == "png" {
+		return png.Encode(out, img)
+	}
+	quality := opt.Quality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	return jpeg.Encode(out, img, &jpeg.Options{Quality: quality})
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"embed"
+	"flag"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/wailsapp/wails/v2"
@@ -16,20 +22,51 @@ var assets embed.FS
 
 type FileLoader struct {
 	http.Handler
-	app *App
+	app    *App
+	config *ThumbnailConfig
+	hls    *HLSTranscoder
+}
+
+func NewFileLoader(app *App) *FileLoader {
+	config := NewThumbnailConfig(".file_manager_cache")
+	return &FileLoader{app: app, config: config, hls: NewHLSTranscoder(config, 2)}
+}
+
+// storage returns the storage of the user in the server mode.
+func (h *FileLoader) storage(req *http.Request) *Storage {
+	if s := SessionFromContext(req.Context()); s != nil {
+		return s.Storage
+	}
+	return h.app.storage
 }
 
 func (h *FileLoader) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Has("share") {
+		h.serveShare(res, req)
+		return
+	}
+	if req.URL.Query().Has("upload") {
+		h.serveUpload(res, req)
+		return
+	}
 	filePath := req.URL.Query().Get("download")
 	log.Println(filePath)
 	if filePath == "" {
 		return
 	}
+	v := h.storage(req).v
+	if SessionFromContext(req.Context()) != nil {
+		// Thumbnails and transcoding may read the file without the restricted volume.
+		if CapsAt(v, filePath)&Read == 0 {
+			http.Error(res, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
 
 	if req.URL.Query().Get("mode") == "thumbnail" {
-		config := &ThumbnailConfig{CacheDir: ".file_manager_cache"}
+		srcType := ThumbnailSourceType(MimeTypeOf(v, filePath, nil, false))
 		select {
-		case cachePath := <-RequestThumbnail(h.app.storage.v, "image", filePath, "", config):
+		case cachePath := <-RequestThumbnail(v, srcType, filePath, "", h.config):
 			if cachePath != "" {
 				res.Header().Set("content-type", "image/jpeg")
 				http.ServeFile(res, req, cachePath)
@@ -41,14 +78,152 @@ func (h *FileLoader) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	res.Header().Set("content-type", MimeTypeByFilename(filePath))
-	http.ServeFileFS(res, req, h.app.storage.v, filePath)
+	if req.URL.Query().Get("mode") == "animated_thumbnail" {
+		srcType := ThumbnailSourceType(MimeTypeOf(v, filePath, nil, false))
+		select {
+		case cachePath := <-RequestAnimatedThumbnail(v, srcType, filePath, "", h.config):
+			if cachePath != "" {
+				res.Header().Set("content-type", "image/gif")
+				http.ServeFile(res, req, cachePath)
+				return
+			}
+		case <-time.After(15 * time.Second):
+		}
+		http.NotFound(res, req)
+		return
+	}
+
+	if req.URL.Query().Get("mode") == "hls" {
+		h.serveHLS(res, req, v, filePath)
+		return
+	}
+
+	if req.URL.Query().Get("mode") == "zip" {
+		h.serveZip(res, req, v, filePath)
+		return
+	}
+
+	if req.URL.Query().Get("mode") == "convert" {
+		h.serveConvertedImage(res, req, v, filePath)
+		return
+	}
+
+	setContentHeaders(res, req, MimeTypeOf(v, filePath, nil, false), filePath, req.URL.Query().Get("disposition") == "attachment")
+	http.ServeFileFS(res, req, v, filePath)
+}
+
+// setContentHeaders sets the headers to serve a file without running its scripts in the origin of the app.
+// Use PreviewServer to render HTML files.
+func setContentHeaders(res http.ResponseWriter, req *http.Request, mimeType, name string, attachment bool) {
+	// Scripts in SVG files don't run in <img>.
+	if !(req.Header.Get("sec-fetch-dest") == "image" && strings.HasPrefix(mimeType, "image/")) {
+		mimeType = SafeMimeType(mimeType)
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	res.Header().Set("content-type", mimeType)
+	res.Header().Set("x-content-type-options", "nosniff")
+	// PDF viewers don't work in sandboxed documents.
+	if !strings.HasPrefix(mimeType, "application/pdf") {
+		res.Header().Set("content-security-policy", "sandbox")
+	}
+	disposition := "inline"
+	if attachment {
+		disposition = "attachment"
+	}
+	res.Header().Set("content-disposition", disposition+"; filename*=UTF-8''"+url.PathEscape(path.Base(name)))
+}
+
+func (h *FileLoader) serveConvertedImage(res http.ResponseWriter, req *http.Request, v Volume, filePath string) {
+	q := req.URL.Query()
+	opt := &ImageConvertOptions{Format: q.Get("format")}
+	opt.MaxWidth, _ = strconv.Atoi(q.Get("w"))
+	opt.MaxHeight, _ = strconv.Atoi(q.Get("h"))
+	opt.Quality, _ = strconv.Atoi(q.Get("q"))
+	format, err := NormalizeImageFormat(opt.Format)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	in, err := v.Open(filePath)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer in.Close()
+
+	var buf bytes.Buffer
+	if err := ConvertImage(in, &buf, opt); err != nil {
+		log.Println("Failed to convert image ", filePath, err)
+		http.Error(res, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	res.Header().Set("content-type", ImageFormatMimeType(format))
+	res.Header().Set("content-length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(res)
+}
+
+func (h *FileLoader) serveHLS(res http.ResponseWriter, req *http.Request, v Volume, filePath string) {
+	q := req.URL.Query()
+	if q.Has("stop") {
+		h.hls.Stop(filePath)
+		return
+	}
+	if segment := q.Get("segment"); segment != "" {
+		p, err := h.hls.Segment(filePath, segment)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		res.Header().Set("content-type", "video/mp2t")
+		http.ServeFile(res, req, p)
+		return
+	}
+
+	baseURL := "volume?download=" + url.QueryEscape(filePath) + "&mode=hls&segment="
+	p, err := h.hls.Playlist(v, filePath, baseURL)
+	if err != nil {
+		log.Println("HLS ", filePath, err)
+		http.Error(res, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	res.Header().Set("content-type", "application/vnd.apple.mpegurl")
+	res.Header().Set("cache-control", "no-cache")
+	http.ServeFile(res, req, p)
 }
 
 func main() {
+	serve := flag.String("serve", "", "run as a HTTP server on the address (e.g. 127.0.0.1:8080) instead of opening a window")
+	accessFile := flag.String("access", "", "authentication and access rules for -serve and the WebDAV server (JSON)")
+	previewAddr := flag.String("preview", "", "address to render HTML previews on (default: a random local port)")
+	previewURL := flag.String("preview-url", "", "URL of the preview server seen by browsers")
+	flag.Parse()
+
 	path := "/"
 	// Create an instance of the app structure
 	app := NewApp(path)
+	app.preview.BaseURL = *previewURL
+	if *previewAddr != "" {
+		if err := app.preview.Start(*previewAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *accessFile != "" {
+		conf, err := LoadAccessConfig(*accessFile)
+		if err == nil {
+			app.access, err = NewAccessControl(conf, app.storage)
+		}
+		if err != nil {
+			log.Fatal(*accessFile, err)
+		}
+	}
+
+	if *serve != "" {
+		log.Fatal(RunServer(app, *serve, app.access))
+	}
 
 	// Create application with options
 	err := wails.Run(&options.App{
@@ -57,7 +232,7 @@ func main() {
 		Height: 768,
 		AssetServer: &assetserver.Options{
 			Assets:  assets,
-			Handler: &FileLoader{app: app},
+			Handler: NewFileLoader(app),
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
@@ -14,6 +14,11 @@ var contentTypes = map[string]string{
 	".f4v":  "video/mp4",
 	".webm": "video/webm",
 	".ogv":  "video/ogv",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".wmv":  "video/x-ms-wmv",
+	".flv":  "video/x-flv",
 
 	// image
 	".jpeg": "image/jpeg",
@@ -22,6 +27,8 @@ var contentTypes = map[string]string{
 	".png":  "image/png",
 	".bmp":  "image/bmp",
 	".webp": "image/webp",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
 	".svg":  "image/svg+xml",
 
 	// audio
@@ -30,23 +37,81 @@ var contentTypes = map[string]string{
 	".ogg": "audio/ogg",
 	".mid": "audio/midi",
 
+	// text
+	".txt":  "text/plain",
+	".log":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".toml": "application/toml",
+	".ini":  "text/plain",
+	".go":   "text/x-go",
+	".c":    "text/x-c",
+	".h":    "text/x-c",
+	".cpp":  "text/x-c++",
+	".hpp":  "text/x-c++",
+	".java": "text/x-java",
+	".py":   "text/x-python",
+	".rs":   "text/x-rust",
+	".js":   "text/javascript",
+	".ts":   "text/x-typescript",
+	".css":  "text/css",
+	".sh":   "text/x-shellscript",
+
 	".zip": "archive",
 }
 
+// textMimeTypes are also listed in frontend/src/main.js.
+var textMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/yaml":       true,
+	"application/toml":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+}
+
 var UnsafeMimeTypeReplace = map[string]string{
-	"text/html":     "text/plain",
-	"text/xml":      "text/plain",
-	"image/svg+xml": "text/plain",
+	"text/html":             "text/plain",
+	"text/xml":              "text/plain",
+	"image/svg+xml":         "text/plain",
+	"application/xhtml+xml": "text/plain",
+	"application/xml":       "text/plain",
+}
+
+// SafeMimeType replaces types which browsers can run scripts in by UnsafeMimeTypeReplace.
+func SafeMimeType(mimeType string) string {
+	t := strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	if r, ok := UnsafeMimeTypeReplace[t]; ok {
+		return r
+	}
+	return mimeType
 }
 
+// MimeTypeByFilename returns the type by the extension. Types in the MimeConfig take precedence over
+// contentTypes, and system types are used if the extension is not found in them.
 func MimeTypeByFilename(name string) string {
 	ext := strings.ToLower(path.Ext(name))
+	t := currentMimeTable()
+	if typ, ok := t.types[ext]; ok {
+		return typ
+	}
 	if typ, ok := contentTypes[ext]; ok {
 		return typ
 	}
+	if typ, ok := t.system[ext]; ok {
+		return typ
+	}
 	return mime.TypeByExtension(ext)
 }
 
+// IsTextMimeType reports whether the content of the type can be shown as plain text.
+func IsTextMimeType(mimeType string) bool {
+	t := strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	return strings.HasPrefix(t, "text/") || textMimeTypes[t]
+}
+
 func ParseMimeType(mimeType string) []string {
 	return strings.FieldsFunc(mimeType, func(r rune) bool {
 		return r == '/' || r == ';'
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// MimeConfig is the user configuration of file types, loaded by LoadMimeConfig.
+//
+//	{
+//	  "types": {".nfo": "text/plain", ".psd": "image/vnd.adobe.photoshop"},
+//	  "associations": [
+//	    {"type": "image/vnd.adobe.photoshop", "previewer": "none", "openWith": ["gimp.desktop"], "external": true},
+//	    {"type": "text/*", "openWith": ["code.desktop"]}
+//	  ]
+//	}
+type MimeConfig struct {
+	// Types maps extensions to types. They take precedence over the built-in and system types.
+	Types        map[string]string  `json:"types,omitempty"`
+	Associations []*AssociationRule `json:"associations,omitempty"`
+}
+
+// AssociationRule sets how files of Type are opened. Type can be "image/*" or "*".
+type AssociationRule struct {
+	Type string `json:"type"`
+	// Previewer replaces the default previewer of the type. "none" disables previewing.
+	Previewer string   `json:"previewer,omitempty"`
+	OpenWith  []string `json:"openWith,omitempty"`
+	External  bool     `json:"external,omitempty"`
+}
+
+// FileAssociation tells the UI how to open a file.
+type FileAssociation struct {
+	MimeType string `json:"type"`
+	// Previewer is the viewer of the app: "image", "video", "audio", "text", "html" or "" if the file cannot be previewed.
+	Previewer string `json:"previewer"`
+	// OpenWith lists applications to open the file with, in the order of preference.
+	OpenWith []string `json:"openWith"`
+	// External is true if the file should be opened with OpenWith rather than the previewer.
+	External bool `json:"external"`
+}
+
+type mimeTable struct {
+	types        map[string]string
+	system       map[string]string
+	associations []*AssociationRule
+}
+
+var mimeTables atomic.Pointer[mimeTable]
+
+func currentMimeTable() *mimeTable {
+	if t := mimeTables.Load(); t != nil {
+		return t
+	}
+	return &mimeTable{}
+}
+
+func defaultMimeConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".file_manager_mimetypes.json"
+	}
+	return filepath.Join(dir, "file-manager", "mimetypes.json")
+}
+
+func xdgDataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share")
+}
+
+// LoadMimeConfig loads the types and associations of file (if exists), the shared MIME database of the user
+// and /etc/mime.types. They are used by MimeTypeByFilename and GetFileAssociation.
+func LoadMimeConfig(file string) error {
+	t := &mimeTable{types: map[string]string{}, system: map[string]string{}}
+	if b, err := os.ReadFile(file); err == nil {
+		var conf MimeConfig
+		if err := json.Unmarshal(b, &conf); err != nil {
+			return err
+		}
+		for ext, typ := range conf.Types {
+			t.types[normalizeExt(ext)] = typ
+		}
+		t.associations = conf.Associations
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	mimeDir := filepath.Join(xdgDataHome(), "mime")
+	if err := loadMimeGlobs(filepath.Join(mimeDir, "globs2"), t.system); errors.Is(err, fs.ErrNotExist) {
+		loadMimeGlobs(filepath.Join(mimeDir, "globs"), t.system)
+	}
+	loadMimeTypesFile("/etc/mime.types", t.system)
+	mimeTables.Store(t)
+	return nil
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// loadMimeGlobs reads the globs2 ("weight:type:glob") or globs ("type:glob") file of shared-mime-info.
+// Only "*.ext" globs are used. Entries already in types are kept.
+func loadMimeGlobs(file string, types map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) >= 3 {
+			fields = fields[1:]
+		}
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "*.") || strings.ContainsAny(fields[1][2:], "*?[") {
+			continue
+		}
+		ext := normalizeExt(fields[1][1:])
+		if _, ok := types[ext]; !ok {
+			types[ext] = fields[0]
+		}
+	}
+	return scanner.Err()
+}
+
+// loadMimeTypesFile reads a mime.types file ("type ext1 ext2 ..."). Entries already in types are kept.
+func loadMimeTypesFile(file string, types map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, ext := range fields[1:] {
+			if strings.HasPrefix(ext, "#") {
+				break
+			}
+			if _, ok := types[normalizeExt(ext)]; !ok {
+				types[normalizeExt(ext)] = fields[0]
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func defaultPreviewer(mimeType string) string {
+	t := strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	switch {
+	case t == "text/html" || t == "application/xhtml+xml":
+		return "html"
+	case IsTextMimeType(t):
+		return "text"
+	}
+	if major, _, _ := strings.Cut(t, "/"); major == "image" || major == "video" || major == "audio" {
+		return major
+	}
+	return ""
+}
+
+// GetFileAssociation returns how to open files of mimeType. The most specific association rule is used.
+func GetFileAssociation(mimeType string) *FileAssociation {
+	t := strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	major, _, _ := strings.Cut(t, "/")
+	a := &FileAssociation{MimeType: mimeType, Previewer: defaultPreviewer(mimeType), OpenWith: []string{}}
+	var rule *AssociationRule
+	best := -1
+	for _, r := range currentMimeTable().associations {
+		score := -1
+		switch r.Type {
+		case t:
+			score = 2
+		case major + "/*":
+			score = 1
+		case "*":
+			score = 0
+		}
+		if score > best {
+			rule, best = r, score
+		}
+	}
+	if rule != nil {
+		if rule.Previewer == "none" {
+			a.Previewer = ""
+		} else if rule.Previewer != "" {
+			a.Previewer = rule.Previewer
+		}
+		a.OpenWith = append(a.OpenWith, rule.OpenWith...)
+		a.External = rule.External
+	}
+	return a
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	sniffLen          = 512
+	sniffCacheEntries = 4096
+)
+
+type magicSignature struct {
+	offset   int
+	magic    string
+	mimeType string
+}
+
+// magicSignatures are checked before http.DetectContentType. Types follow contentTypes.
+var magicSignatures = []magicSignature{
+	{0, "PK\x03\x04", "archive"},
+	{0, "PK\x05\x06", "archive"},
+	{0, "Rar!\x1a\x07", "archive"},
+	{0, "7z\xbc\xaf\x27\x1c", "archive"},
+	{0, "%PDF-", "application/pdf"},
+	{0, "fLaC", "audio/flac"},
+	{0, "ID3", "audio/mp3"},
+	{0, "MThd", "audio/midi"},
+	{0, "FLV\x01", "video/x-flv"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "\x30\x26\xb2\x75\x8e\x66\xcf\x11", "video/x-ms-wmv"},
+	{8, "WEBP", "image/webp"},
+	{8, "AVI ", "video/x-msvideo"},
+	{8, "WAVE", "audio/wav"},
+}
+
+// ftypBrands maps ISO base media file brands to types. Other brands are video/mp4.
+var ftypBrands = map[string]string{
+	"qt  ": "video/quicktime",
+	"M4A ": "audio/mp4",
+	"M4B ": "audio/mp4",
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"avif": "image/avif",
+}
+
+// SniffMimeType detects the type from the first bytes of a file. It returns "" if the type is unknown.
+func SniffMimeType(head []byte) string {
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	for _, s := range magicSignatures {
+		if len(head) >= s.offset+len(s.magic) && string(head[s.offset:s.offset+len(s.magic)]) == s.magic {
+			if s.offset == 8 && string(head[:4]) != "RIFF" {
+				continue
+			}
+			return s.mimeType
+		}
+	}
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		if t, ok := ftypBrands[string(head[8:12])]; ok {
+			return t
+		}
+		return "video/mp4"
+	}
+	if bytes.HasPrefix(head, []byte("\x1a\x45\xdf\xa3")) {
+		if bytes.Contains(head, []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	}
+	if len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0 && head[1]&0x06 != 0 {
+		return "audio/mp3"
+	}
+	t := http.DetectContentType(head)
+	switch {
+	case t == "application/octet-stream":
+		return ""
+	case t == "application/zip" || t == "application/x-rar-compressed":
+		return "archive"
+	}
+	return t
+}
+
+// isGenericMimeType reports whether t is a container or fallback type, which the extension may describe more precisely.
+func isGenericMimeType(t string) bool {
+	return t == "" || t == "archive" || strings.HasPrefix(t, "text/plain") || strings.HasPrefix(t, "text/xml")
+}
+
+type sniffCacheKey struct {
+	name    string
+	size    int64
+	modTime int64
+}
+
+var sniffCache = struct {
+	sync.Mutex
+	types map[sniffCacheKey]string
+}{types: map[sniffCacheKey]string{}}
+
+// MimeTypeOf returns the type of the file name in fsys. The type is detected from the content if the extension
+// is unknown or verify is true. Detected types are cached while the size and modification time are unchanged.
+// info can be nil.
+func MimeTypeOf(fsys fs.FS, name string, info fs.FileInfo, verify bool) string {
+	byName := MimeTypeByFilename(name)
+	if byName != "" && !verify {
+		return byName
+	}
+	if info == nil {
+		var err error
+		if info, err = fs.Stat(fsys, name); err != nil {
+			return byName
+		}
+	}
+	if info.IsDir() {
+		return byName
+	}
+	key := sniffCacheKey{name: name, size: info.Size(), modTime: info.ModTime().UnixNano()}
+	sniffCache.Lock()
+	sniffed, ok := sniffCache.types[key]
+	sniffCache.Unlock()
+	if !ok {
+		sniffed = sniffFile(fsys, name)
+		sniffCache.Lock()
+		if len(sniffCache.types) >= sniffCacheEntries {
+			clear(sniffCache.types)
+		}
+		sniffCache.types[key] = sniffed
+		sniffCache.Unlock()
+	}
+	if isGenericMimeType(sniffed) && byName != "" {
+		return byName
+	}
+	return sniffed
+}
+
+func sniffFile(fsys fs.FS, name string) string {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, buf)
+	if n == 0 {
+		return ""
+	}
+	return SniffMimeType(buf[:n])
+}
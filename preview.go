@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const previewTTL = 30 * time.Minute
+
+type previewEntry struct {
+	storage *Storage
+	dir     string
+	expires time.Time
+}
+
+// PreviewServer renders untrusted HTML files on an origin separated from the app.
+// Files are served with their original types at <BaseURL>/<id>/<path>, where id is issued by URL for a directory.
+// Scripts run in a sandbox with a unique origin, so they cannot access the bindings, cookies or storage of the app.
+type PreviewServer struct {
+	// BaseURL is the URL of the server seen by browsers. It defaults to the listening address.
+	BaseURL string
+	mutex   sync.Mutex
+	entries map[string]*previewEntry
+	server  *http.Server
+}
+
+func NewPreviewServer() *PreviewServer {
+	return &PreviewServer{entries: map[string]*previewEntry{}}
+}
+
+// Start listens on addr (e.g. "127.0.0.1:0"). It does nothing if the server is already running.
+func (p *PreviewServer) Start(addr string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.server != nil {
+		return nil
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = "http://" + l.Addr().String()
+	}
+	p.server = &http.Server{Handler: p}
+	go p.server.Serve(l)
+	return nil
+}
+
+// URL returns a URL of name in s which expires in previewTTL. Relative links in the file can access the same directory tree.
+func (p *PreviewServer) URL(s *Storage, name string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	name = path.Clean(name)
+	dir := path.Dir(name)
+	if CapsAt(s.v, dir)&Read == 0 {
+		// Only the file itself is readable.
+		dir = name
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	for k, e := range p.entries {
+		if now.After(e.expires) {
+			delete(p.entries, k)
+		}
+	}
+	p.entries[id] = &previewEntry{storage: s, dir: dir, expires: now.Add(previewTTL)}
+	return p.BaseURL + "/" + id + "/" + (&url.URL{Path: name}).EscapedPath(), nil
+}
+
+func (p *PreviewServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	id, name, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	p.mutex.Lock()
+	e := p.entries[id]
+	p.mutex.Unlock()
+	name = path.Clean("/" + name)[1:]
+	if e == nil || time.Now().After(e.expires) || !(e.dir == "." || name == e.dir || strings.HasPrefix(name, e.dir+"/")) {
+		http.NotFound(res, req)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	mimeType := MimeTypeOf(e.storage.v, name, nil, false)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	res.Header().Set("content-type", mimeType)
+	res.Header().Set("x-content-type-options", "nosniff")
+	res.Header().Set("content-security-policy", "sandbox allow-scripts allow-forms allow-popups allow-modals")
+	res.Header().Set("referrer-policy", "no-referrer")
+	res.Header().Set("cache-control", "private, no-store")
+	http.ServeFileFS(res, req, e.storage.v, name)
+}
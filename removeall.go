@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// RemoveReport is the progress and the result of a RemoveTask.
+type RemoveReport struct {
+	Path string `json:"path"`
+	// TotalFiles and TotalBytes are counted before removing. Directories are not counted as files.
+	TotalFiles int              `json:"totalFiles"`
+	TotalBytes int64            `json:"totalBytes"`
+	Files      int              `json:"files"`
+	Bytes      int64            `json:"bytes"`
+	Failures   []*RemoveFailure `json:"failures"`
+	Done       bool             `json:"done"`
+	Canceled   bool             `json:"canceled"`
+}
+
+type RemoveFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+	err   error
+}
+
+// RemoveTask removes a file or a directory recursively with ReadDir and Remove of the volume.
+// The root of the volume and mount points can not be removed.
+// Items which failed to be removed are reported and the other items are still removed.
+type RemoveTask struct {
+	v      Volume
+	ctx    context.Context
+	cancel context.CancelFunc
+	mutex  sync.Mutex
+	report RemoveReport
+}
+
+func NewRemoveTask(ctx context.Context, v Volume, name string) *RemoveTask {
+	ctx, cancel := context.WithCancel(ctx)
+	return &RemoveTask{v: v, ctx: ctx, cancel: cancel, report: RemoveReport{Path: name, Failures: []*RemoveFailure{}}}
+}
+
+// RemoveAll removes name recursively and returns the first error.
+func RemoveAll(ctx context.Context, v Volume, name string) error {
+	t := NewRemoveTask(ctx, v, name)
+	t.Run()
+	return t.Err()
+}
+
+func (t *RemoveTask) Cancel() {
+	t.cancel()
+}
+
+// Report returns a snapshot of the progress.
+func (t *RemoveTask) Report() *RemoveReport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	r := t.report
+	r.Failures = append([]*RemoveFailure{}, r.Failures...)
+	return &r
+}
+
+// Err returns the first failure, context.Canceled if the task is canceled or nil.
+func (t *RemoveTask) Err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.report.Failures) > 0 {
+		return t.report.Failures[0].err
+	}
+	if t.report.Canceled {
+		return context.Canceled
+	}
+	return nil
+}
+
+func (t *RemoveTask) fail(name string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.report.Failures = append(t.report.Failures, &RemoveFailure{Path: name, Error: err.Error(), err: err})
+}
+
+func (t *RemoveTask) isMountPoint(name string) bool {
+	m, ok := t.v.(interface{ IsMountPoint(string) bool })
+	return name == "." || ok && m.IsMountPoint(name)
+}
+
+func (t *RemoveTask) Run() {
+	defer func() {
+		t.mutex.Lock()
+		t.report.Done = true
+		t.report.Canceled = t.ctx.Err() != nil
+		t.mutex.Unlock()
+		t.cancel()
+	}()
+	name := t.report.Path
+	if !fs.ValidPath(name) {
+		t.fail(name, &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid})
+		return
+	}
+	if t.isMountPoint(name) {
+		t.fail(name, &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission})
+		return
+	}
+	// Symbolic links are removed without following them.
+	st, err := Lstat(t.v, name)
+	if err != nil {
+		t.fail(name, err)
+		return
+	}
+	if err := t.count(name, st); err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			t.fail(pathErr.Path, err)
+		} else if !errors.Is(err, context.Canceled) {
+			t.fail(name, err)
+		}
+		return
+	}
+	t.remove(name, fs.FileInfoToDirEntry(st))
+}
+
+// count counts the files to remove. Mount points in the tree abort the task before anything is removed.
+func (t *RemoveTask) count(name string, st fs.FileInfo) error {
+	if !st.IsDir() {
+		t.mutex.Lock()
+		t.report.TotalFiles, t.report.TotalBytes = 1, st.Size()
+		t.mutex.Unlock()
+		return nil
+	}
+	return fs.WalkDir(t.v, name, func(p string, d fs.DirEntry, err error) error {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			// Reported when removing.
+			return nil
+		}
+		if t.isMountPoint(p) {
+			return &fs.PathError{Op: "remove", Path: p, Err: fs.ErrPermission}
+		}
+		if d.IsDir() {
+			return nil
+		}
+		var size int64
+		if info, err := d.Info(); err == nil {
+			size = info.Size()
+		}
+		t.mutex.Lock()
+		t.report.TotalFiles++
+		t.report.TotalBytes += size
+		t.mutex.Unlock()
+		return nil
+	})
+}
+
+// remove returns false if name or any item in it is not removed.
+func (t *RemoveTask) remove(name string, d fs.DirEntry) bool {
+	if t.ctx.Err() != nil {
+		return false
+	}
+	if d.IsDir() {
+		entries, err := fs.ReadDir(t.v, name)
+		if err != nil {
+			t.fail(name, err)
+			return false
+		}
+		removed := true
+		for _, e := range entries {
+			removed = t.remove(path.Join(name, e.Name()), e) && removed
+		}
+		if !removed {
+			return false
+		}
+	}
+	var size int64
+	if info, err := d.Info(); err == nil && !d.IsDir() {
+		size = info.Size()
+	}
+	if err := t.v.Remove(name); err != nil {
+		t.fail(name, err)
+		return false
+	}
+	if !d.IsDir() {
+		t.mutex.Lock()
+		t.report.Files++
+		t.report.Bytes += size
+		t.mutex.Unlock()
+	}
+	return true
+}
+
+// RemoveTasks keeps the RemoveTasks started by App until their reports are read after completion.
+type RemoveTasks struct {
+	mutex sync.Mutex
+	tasks map[string]*RemoveTask
+}
+
+func NewRemoveTasks() *RemoveTasks {
+	return &RemoveTasks{tasks: map[string]*RemoveTask{}}
+}
+
+// Start adds a task removing name to d and returns the ID of the task.
+func (r *RemoveTasks) Start(d *Dispatcher, v Volume, name string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := "remove:" + base64.RawURLEncoding.EncodeToString(b)
+	t := NewRemoveTask(context.Background(), v, name)
+	r.mutex.Lock()
+	r.tasks[id] = t
+	r.mutex.Unlock()
+	if d.TryAddWithId(t, id) == nil {
+		r.mutex.Lock()
+		delete(r.tasks, id)
+		r.mutex.Unlock()
+		return "", errors.New("too many tasks")
+	}
+	return id, nil
+}
+
+// Report returns the progress of the task id. Finished tasks are forgotten after this.
+func (r *RemoveTasks) Report(id string) *RemoveReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	t := r.tasks[id]
+	if t == nil {
+		return nil
+	}
+	report := t.Report()
+	if report.Done {
+		delete(r.tasks, id)
+	}
+	return report
+}
+
+func (r *RemoveTasks) Cancel(id string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	t := r.tasks[id]
+	if t != nil {
+		t.Cancel()
+	}
+	return t != nil
+}
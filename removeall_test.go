@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveAllSymlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "important"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "important", "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "tree"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"toplink", "tree/link"} {
+		if err := os.Symlink(filepath.Join(dir, "important"), filepath.Join(dir, link)); err != nil {
+			t.Skip("symlink is not supported:", err)
+		}
+	}
+
+	v := NewStorage(NewWritableDirFS(dir)).v
+	for _, name := range []string{"toplink", "tree"} {
+		if err := RemoveAll(context.Background(), v, name); err != nil {
+			t.Fatal(name, err)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Error(name, "is not removed:", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "important", "data.txt")); err != nil {
+		t.Error("target of the link is removed:", err)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const rpcMaxRequestSize = 1024 * 1024
+
+// rpcClientJS defines window.go.main.App like Wails bindings, calling the methods over HTTP.
+const rpcClientJS = `window.go = window.go || {main: {App: new Proxy({}, {
+	get: (_, name) => async (...args) => {
+		let res = await fetch('rpc/' + name, {method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(args)});
+		if (!res.ok) { throw new Error(await res.text()); }
+		return await res.json();
+	}
+})}};
+`
+
+// rpcUserMethods are App methods available to non-admin users. They only access files through the Session storage.
+var rpcUserMethods = map[string]bool{
+	"Greet": true, "GetFiles": true, "Mkdir": true, "Rename": true, "Remove": true, "ConvertImage": true,
+	"PreviewURL": true, "GetMimeType": true, "GetFileAssociation": true, "MoveToTrash": true,
+	"RemoveAll": true, "GetRemoveStatus": true, "CancelRemove": true, "TransferFiles": true,
+	"Undo": true, "Redo": true, "GetOperations": true, "PreviewBulkRename": true, "BulkRename": true,
+}
+
+// NewServer returns a handler serving the frontend, files and App methods without Wails.
+// App methods are called with POST /rpc/<Method> and a JSON array of the arguments.
+// Requests are authenticated by access if it is not nil.
+func NewServer(app *App, access *AccessControl) http.Handler {
+	frontend, err := fs.Sub(assets, "frontend/src")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(frontend)))
+	loader := NewFileLoader(app)
+	mux.Handle("/volume", loader)
+	mux.Handle("/rpc/", &rpcHandler{app: app})
+	mux.HandleFunc("/rpc.js", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("content-type", "text/javascript")
+		io.WriteString(res, rpcClientJS)
+	})
+	if access == nil {
+		return mux
+	}
+	authorized := access.Handler(mux)
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/volume" && req.URL.Query().Has("share") {
+			// Share links are authorized by the share token.
+			loader.serveShare(res, req)
+			return
+		}
+		authorized.ServeHTTP(res, req)
+	})
+}
+
+// RunServer serves the app at addr until it fails. Without access, addr must be a loopback address.
+func RunServer(app *App, addr string, access *AccessControl) error {
+	if access == nil && !isLoopbackAddr(addr) {
+		return fmt.Errorf("authentication (-access) is required to listen on %s", addr)
+	}
+	app.startup(context.Background())
+	if access == nil {
+		log.Println("WARNING: authentication is not configured")
+	}
+	log.Println("Listening on ", addr)
+	return http.ListenAndServe(addr, NewServer(app, access))
+}
+
+// isLoopbackAddr reports whether addr (host:port) accepts connections only from the local host.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type rpcHandler struct {
+	app *App
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func (h *rpcHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Requiring JSON makes cross-site requests fail the CORS preflight.
+	if mt, _, _ := mime.ParseMediaType(req.Header.Get("content-type")); mt != "application/json" {
+		http.Error(res, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/rpc/")
+	app := h.app
+	if s := SessionFromContext(req.Context()); s != nil && !s.Admin {
+		if !rpcUserMethods[name] {
+			http.Error(res, "forbidden", http.StatusForbidden)
+			return
+		}
+		app = app.withSession(s)
+	}
+	method := reflect.ValueOf(app).MethodByName(name)
+	if !method.IsValid() {
+		http.NotFound(res, req)
+		return
+	}
+
+	var params []json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(req.Body, rpcMaxRequestSize)).Decode(&params); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t := method.Type()
+	if len(params) != t.NumIn() {
+		http.Error(res, "wrong number of arguments", http.StatusBadRequest)
+		return
+	}
+	args := make([]reflect.Value, len(params))
+	for i, p := range params {
+		v := reflect.New(t.In(i))
+		if err := json.Unmarshal(p, v.Interface()); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		args[i] = v.Elem()
+	}
+
+	out := method.Call(args)
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out = out[:n-1]
+	}
+	var result any
+	if len(out) > 0 {
+		result = out[0].Interface()
+	}
+	res.Header().Set("content-type", "application/json")
+	json.NewEncoder(res).Encode(result)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrShareNotFound = errors.New("share not found")
+	ErrShareExpired  = errors.New("share expired")
+)
+
+// Share is a read-only link to a file or folder.
+// It is accessed with volume?share=<Token>&download=<path in the folder>.
+type Share struct {
+	ID           string `json:"id"`
+	Token        string `json:"token"`
+	Path         string `json:"path"`
+	Created      int64  `json:"created"`
+	Expires      int64  `json:"expires"` // unix millis, 0 for no expiry
+	MaxDownloads int    `json:"maxDownloads"`
+	Downloads    int    `json:"downloads"`
+	AllowList    bool   `json:"allowList"`
+}
+
+func (s *Share) expired(now time.Time) bool {
+	return s.Expires > 0 && now.UnixMilli() >= s.Expires
+}
+
+// ShareStore keeps shares in a JSON file with the key to sign the tokens.
+type ShareStore struct {
+	file   string
+	mutex  sync.Mutex
+	secret []byte
+	shares map[string]*Share
+}
+
+type shareStoreData struct {
+	Secret []byte   `json:"secret"`
+	Shares []*Share `json:"shares"`
+}
+
+func defaultShareStoreFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".file_manager_shares.json"
+	}
+	return filepath.Join(dir, "file-manager", "shares.json")
+}
+
+// NewShareStore loads shares from file. If file is "", shares are kept only in memory.
+func NewShareStore(file string) (*ShareStore, error) {
+	s := &ShareStore{file: file, shares: map[string]*Share{}}
+	b, err := os.ReadFile(file)
+	if file == "" || errors.Is(err, fs.ErrNotExist) {
+		s.secret = make([]byte, 32)
+		_, err = rand.Read(s.secret)
+		return s, err
+	} else if err != nil {
+		return nil, err
+	}
+	var data shareStoreData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Secret) == 0 {
+		return nil, errors.New("no secret in " + file)
+	}
+	s.secret = data.Secret
+	for _, sh := range data.Shares {
+		s.shares[sh.ID] = sh
+	}
+	return s, nil
+}
+
+func (s *ShareStore) sign(id, name string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "\n" + name + "\n" + strconv.FormatInt(expires, 10)))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// save writes the shares to the file. s.mutex must be held.
+func (s *ShareStore) save() error {
+	now := time.Now()
+	data := shareStoreData{Secret: s.secret, Shares: []*Share{}}
+	for id, sh := range s.shares {
+		if sh.expired(now) {
+			delete(s.shares, id)
+			continue
+		}
+		data.Shares = append(data.Shares, sh)
+	}
+	if s.file == "" {
+		return nil
+	}
+	sort.Slice(data.Shares, func(i, j int) bool { return data.Shares[i].Created < data.Shares[j].Created })
+	b, err := json.MarshalIndent(&data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0700); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// Create adds a share of name. ttl <= 0 means no expiry and maxDownloads <= 0 means unlimited.
+func (s *ShareStore) Create(name string, ttl time.Duration, maxDownloads int, allowList bool) (*Share, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sh := &Share{ID: base64.RawURLEncoding.EncodeToString(b), Path: path.Clean(name), Created: now.UnixMilli(), AllowList: allowList}
+	if ttl > 0 {
+		sh.Expires = now.Add(ttl).UnixMilli()
+	}
+	if maxDownloads > 0 {
+		sh.MaxDownloads = maxDownloads
+	}
+	sh.Token = s.sign(sh.ID, sh.Path, sh.Expires)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.shares[sh.ID] = sh
+	if err := s.save(); err != nil {
+		delete(s.shares, sh.ID)
+		return nil, err
+	}
+	c := *sh
+	return &c, nil
+}
+
+func (s *ShareStore) List() []*Share {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	shares := []*Share{}
+	for _, sh := range s.shares {
+		if !sh.expired(now) {
+			c := *sh
+			shares = append(shares, &c)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Created < shares[j].Created })
+	return shares
+}
+
+func (s *ShareStore) Revoke(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.shares[id]; !ok {
+		return ErrShareNotFound
+	}
+	delete(s.shares, id)
+	return s.save()
+}
+
+// lookup returns the share of a valid token. s.mutex must be held.
+func (s *ShareStore) lookup(token string) (*Share, error) {
+	id, _, _ := strings.Cut(token, ".")
+	sh, ok := s.shares[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.sign(sh.ID, sh.Path, sh.Expires))) != 1 {
+		return nil, ErrShareNotFound
+	}
+	if sh.expired(time.Now()) || sh.MaxDownloads > 0 && sh.Downloads >= sh.MaxDownloads {
+		return nil, ErrShareExpired
+	}
+	return sh, nil
+}
+
+func (s *ShareStore) Get(token string) (*Share, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sh, err := s.lookup(token)
+	if err != nil {
+		return nil, err
+	}
+	c := *sh
+	return &c, nil
+}
+
+// CountDownload increments the download count of the share, failing if the limit is reached.
+func (s *ShareStore) CountDownload(token string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sh, err := s.lookup(token)
+	if err != nil {
+		return err
+	}
+	sh.Downloads++
+	if sh.MaxDownloads > 0 {
+		// Unlimited shares are not saved on every download.
+		return s.save()
+	}
+	return nil
+}
+
+var shareIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>{{.Name}}</title></head>
+<body><h1>{{.Name}}</h1><ul>
+{{if .Parent}}<li><a href="{{.Parent}}">../</a></li>{{end}}
+{{range .Items}}<li><a href="{{.URL}}">{{.Name}}</a>{{if not .Dir}} ({{.Size}} bytes){{end}}</li>
+{{end}}</ul></body></html>
+`))
+
+type shareIndexItem struct {
+	Name string
+	URL  string
+	Dir  bool
+	Size int64
+}
+
+func shareURL(token, rel string) string {
+	return "volume?share=" + url.QueryEscape(token) + "&download=" + url.QueryEscape(rel)
+}
+
+// serveShare serves a file or folder listing of a share. Shares do not require the authentication of the server mode.
+func (h *FileLoader) serveShare(res http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	token := q.Get("share")
+	sh, err := h.app.shares.Get(token)
+	if err != nil {
+		code := http.StatusNotFound
+		if err == ErrShareExpired {
+			code = http.StatusGone
+		}
+		http.Error(res, err.Error(), code)
+		return
+	}
+	rel := strings.TrimPrefix(path.Clean("/"+q.Get("download")), "/")
+	filePath := path.Join(sh.Path, rel)
+	v := h.app.storage.Restrict([]AccessRule{{Path: sh.Path, Caps: CapReadOnly}}).v
+	stat, err := v.Stat(filePath)
+	if err != nil {
+		http.NotFound(res, req)
+		return
+	}
+	if stat.IsDir() {
+		if !sh.AllowList {
+			http.Error(res, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.serveShareIndex(res, v, token, rel, filePath)
+		return
+	}
+	// Range requests are counted too, since a range can cover the whole file.
+	if req.Method == http.MethodGet {
+		if err := h.app.shares.CountDownload(token); err != nil {
+			http.Error(res, err.Error(), http.StatusGone)
+			return
+		}
+	}
+	setContentHeaders(res, req, MimeTypeOf(v, filePath, stat, false), filePath, true)
+	http.ServeFileFS(res, req, v, filePath)
+}
+
+func (h *FileLoader) serveShareIndex(res http.ResponseWriter, v Volume, token, rel, dir string) {
+	entries, err := fs.ReadDir(v, dir)
+	if err != nil {
+		log.Println("share ", dir, err)
+		http.Error(res, "forbidden", http.StatusForbidden)
+		return
+	}
+	data := struct {
+		Name   string
+		Parent string
+		Items  []shareIndexItem
+	}{Name: path.Base(dir)}
+	if rel != "" {
+		data.Parent = shareURL(token, path.Dir(rel))
+	}
+	for _, e := range entries {
+		item := shareIndexItem{Name: e.Name(), URL: shareURL(token, path.Join(rel, e.Name())), Dir: e.IsDir()}
+		if item.Dir {
+			item.Name += "/"
+		} else if info, err := e.Info(); err == nil {
+			item.Size = info.Size()
+		}
+		data.Items = append(data.Items, item)
+	}
+	res.Header().Set("content-type", "text/html; charset=utf-8")
+	shareIndexTemplate.Execute(res, &data)
+}
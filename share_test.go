@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestShareRangeDownloadLimit(t *testing.T) {
+	v := NewMemFS()
+	w, err := v.OpenWriter("file.txt", os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "hello")
+	w.Close()
+	shares, err := NewShareStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := shares.Create("file.txt", time.Hour, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &FileLoader{app: &App{storage: NewStorage(v), shares: shares}}
+
+	get := func() int {
+		req := httptest.NewRequest("GET", "/volume?share="+url.QueryEscape(sh.Token), nil)
+		req.Header.Set("Range", "bytes=0-")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res.Code
+	}
+	if code := get(); code != http.StatusPartialContent {
+		t.Fatalf("first download: status %d", code)
+	}
+	if code := get(); code != http.StatusGone {
+		t.Errorf("download over the limit: status %d, want %d", code, http.StatusGone)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"io/fs"
 	"log"
 	"path"
@@ -28,18 +29,42 @@ type FileList struct {
 }
 
 type Storage struct {
-	v    Volume
-	caps Capability
+	v      Volume
+	mounts *mountFS
 }
 
 func NewStorage(v fs.FS) *Storage {
-	return &Storage{v: WrapVolume(v), caps: CapsInvalid}
+	m := NewMountFS(v)
+	return &Storage{v: m, mounts: m}
 }
 
-func (s *Storage) Caps(path string) Capability {
-	if s.caps == CapsInvalid {
-		s.caps = Caps(s.v)
+// Mount attaches v at name. Paths under name are served by v.
+func (s *Storage) Mount(name string, v fs.FS) error {
+	return s.mounts.Mount(name, v)
+}
+
+// Unmount detaches the volume at name and closes it if it is an io.Closer.
+func (s *Storage) Unmount(name string) error {
+	v, rel := s.mounts.ResolveVolume(name)
+	if rel != "." || !s.mounts.IsMountPoint(name) {
+		return &fs.PathError{Op: "unmount", Path: name, Err: fs.ErrInvalid}
 	}
+	if err := s.mounts.Unmount(name); err != nil {
+		return err
+	}
+	if c, ok := v.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Restrict returns a view of s limited to rules. Mounts are shared with s.
+func (s *Storage) Restrict(rules []AccessRule) *Storage {
+	return &Storage{v: NewAccessVolume(s.v, rules), mounts: s.mounts}
+}
+
+func (s *Storage) Caps(path string) Capability {
+	caps := CapsAt(s.v, path)
 	stat, err := s.v.Stat(path)
 	if err != nil || (stat.Mode()&0200) == 0 {
 		if err == nil {
@@ -47,9 +72,9 @@ func (s *Storage) Caps(path string) Capability {
 		} else {
 			log.Println("ERR", path, err)
 		}
-		return s.caps & CapReadOnly
+		return caps & CapReadOnly
 	}
-	return s.caps
+	return caps
 }
 
 func GetMimeType(f fs.DirEntry) string {
@@ -114,8 +139,11 @@ func (s *Storage) Files(dir string, offset, limit int) (*FileList, error) {
 
 	items := []*FileInfo{}
 	for _, f := range files {
-		f.IsDir()
-		items = append(items, ToFileInfo(f))
+		item := ToFileInfo(f)
+		if info, err := f.Info(); err == nil && item.MimeType == "" {
+			item.MimeType = MimeTypeOf(s.v, path.Join(dir, f.Name()), info, false)
+		}
+		items = append(items, item)
 	}
 
 	nextOffset := offset + limit
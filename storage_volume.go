@@ -20,6 +20,7 @@ const (
 	Remove Capability = 64
 	Rename Capability = 128
 	Stat   Capability = 256
+	Trash  Capability = 512
 
 	CapsInvalid = 32768
 	CapReadOnly = Read | Stat
@@ -30,12 +31,18 @@ func (c Capability) ToStrings() []string {
 	if (c & Read) != 0 {
 		caps = append(caps, "read")
 	}
-	if (c & Read) != 0 {
+	if (c & Write) != 0 {
 		caps = append(caps, "write")
 	}
 	if (c & Append) != 0 {
 		caps = append(caps, "append")
 	}
+	if (c & Create) != 0 {
+		caps = append(caps, "create")
+	}
+	if (c & Mkdir) != 0 {
+		caps = append(caps, "mkdir")
+	}
 	if (c & Remove) != 0 {
 		caps = append(caps, "remove")
 	}
@@ -48,6 +55,9 @@ func (c Capability) ToStrings() []string {
 	if (c & Stat) != 0 {
 		caps = append(caps, "stat")
 	}
+	if (c & Trash) != 0 {
+		caps = append(caps, "trash")
+	}
 	return caps
 }
 
@@ -55,6 +65,28 @@ func (c Capability) ToString() string {
 	return strings.Join(c.ToStrings(), ",")
 }
 
+var capabilityNames = map[string]Capability{
+	"read": Read, "write": Write, "append": Append, "truncate": Truncate,
+	"create": Create, "mkdir": Mkdir, "remove": Remove, "rename": Rename, "stat": Stat, "trash": Trash,
+}
+
+// ParseCapabilities converts names returned by ToStrings to Capability. "all" means every capability.
+func ParseCapabilities(names []string) (Capability, error) {
+	var caps Capability
+	for _, name := range names {
+		if name == "all" {
+			caps |= Read | Write | Append | Truncate | Create | Mkdir | Remove | Rename | Stat | Trash
+			continue
+		}
+		c, ok := capabilityNames[name]
+		if !ok {
+			return 0, errors.New("unknown capability: " + name)
+		}
+		caps |= c
+	}
+	return caps, nil
+}
+
 var ErrInvalidOp = errors.New("invalid operation")
 
 type Volume interface {
@@ -108,7 +140,7 @@ func (v *volumeWrapper) Caps() Capability {
 		caps |= Stat
 	}
 	if v.OpenWriterFS != nil {
-		caps |= Write
+		caps |= Write | Create | Append
 	}
 	if v.RemoveFS != nil {
 		caps |= Remove
@@ -192,7 +224,7 @@ func Caps(v fs.FS) Capability {
 		caps |= Stat
 	}
 	if _, ok := v.(OpenWriterFS); ok {
-		caps |= Write
+		caps |= Write | Create | Append
 	}
 	if _, ok := v.(RemoveFS); ok {
 		caps |= Remove
@@ -206,5 +238,16 @@ func Caps(v fs.FS) Capability {
 	if _, ok := v.(TruncateFS); ok {
 		caps |= Truncate
 	}
+	if _, ok := v.(TrashFS); ok {
+		caps |= Trash
+	}
 	return caps
 }
+
+// CapsAt returns the capabilities at name if v has volumes with different capabilities like mountFS.
+func CapsAt(v fs.FS, name string) Capability {
+	if c, ok := v.(interface{ CapsAt(string) Capability }); ok {
+		return c.CapsAt(name)
+	}
+	return Caps(v)
+}
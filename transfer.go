@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+func isSubPath(name, dir string) bool {
+	return dir == "." || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// CopyAll copies src to dst recursively. dst must not exist, and is removed if the copy fails.
+// Symbolic links in src are skipped.
+func CopyAll(v Volume, src, dst string) error {
+	if isSubPath(dst, src) {
+		return &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrInvalid}
+	}
+	if _, err := Lstat(v, dst); err == nil {
+		return &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+	}
+	st, err := Lstat(v, src)
+	if err != nil {
+		return err
+	}
+	if st.Mode()&fs.ModeSymlink != 0 {
+		return &fs.PathError{Op: "copy", Path: src, Err: ErrInvalidOp}
+	}
+	if err := copyAll(v, src, dst, st); err != nil {
+		RemoveAll(context.Background(), v, dst)
+		return err
+	}
+	return nil
+}
+
+func copyAll(v Volume, src, dst string, st fs.FileInfo) error {
+	if !st.IsDir() {
+		return copyFile(v, src, dst)
+	}
+	if err := v.Mkdir(dst, 0777); err != nil {
+		return err
+	}
+	entries, err := fs.ReadDir(v, src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Type()&fs.ModeSymlink != 0 {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		if err := copyAll(v, path.Join(src, e.Name()), path.Join(dst, e.Name()), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(v Volume, src, dst string) error {
+	in, err := v.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := v.OpenWriter(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		v.Remove(dst)
+	}
+	return err
+}
+
+// MoveAll renames src to dst. Files on different volumes or devices are copied and removed.
+func MoveAll(v Volume, src, dst string) error {
+	if _, err := Lstat(v, dst); err == nil {
+		return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+	}
+	err := v.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		v1, _ := resolveVolume(v, src)
+		v2, _ := resolveVolume(v, dst)
+		if v1 == v2 || !errors.Is(err, fs.ErrInvalid) {
+			return err
+		}
+	}
+	if err := CopyAll(v, src, dst); err != nil {
+		return err
+	}
+	return RemoveAll(context.Background(), v, src)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyAllSymlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "sub", "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(dir, "src", "sub", "loop")); err != nil {
+		t.Skip("symlink is not supported:", err)
+	}
+
+	v := NewStorage(NewWritableDirFS(dir)).v
+	if err := CopyAll(v, "src", "dst"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "dst", "sub", "file")); err != nil || string(b) != "data" {
+		t.Error("file is not copied", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "dst", "sub", "loop")); !os.IsNotExist(err) {
+		t.Error("symbolic link is copied", err)
+	}
+	if err := CopyAll(v, "src/sub/loop", "dst2"); err == nil {
+		t.Error("CopyAll: succeeded for a symbolic link")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "dst2")); !os.IsNotExist(err) {
+		t.Error("dst2 is created", err)
+	}
+}
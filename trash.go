@@ -0,0 +1,12 @@
+package main
+
+// TrashItem is a file or directory in the trash.
+type TrashItem struct {
+	// ID identifies the item for RestoreFromTrash.
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	OriginalPath string `json:"originalPath"` // path in the OS file system
+	DeletionTime int64  `json:"deletionTime"`
+	Size         int64  `json:"size"`
+	IsDir        bool   `json:"isDir"`
+}
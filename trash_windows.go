@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+// The Recycle Bin is not supported yet.
+
+func ListTrash() ([]*TrashItem, error) {
+	return nil, ErrInvalidOp
+}
+
+func RestoreFromTrash(id string) error {
+	return ErrInvalidOp
+}
+
+func EmptyTrash() error {
+	return ErrInvalidOp
+}
@@ -0,0 +1,309 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Trash of the FreeDesktop.org Trash specification.
+// Files are moved to $XDG_DATA_HOME/Trash, or $topdir/.Trash/$uid or $topdir/.Trash-$uid on other file systems.
+
+const trashInfoTimeFormat = "2006-01-02T15:04:05"
+
+type trashDir struct {
+	dir string
+	top string // mount point for per-mount trash directories. Original paths are relative to it.
+}
+
+func homeTrashDir() string {
+	return filepath.Join(xdgDataHome(), "Trash")
+}
+
+func deviceOf(p string) (uint64, error) {
+	st, err := os.Lstat(p)
+	if err != nil {
+		return 0, err
+	}
+	sys, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, ErrInvalidOp
+	}
+	return uint64(sys.Dev), nil
+}
+
+// mountTop returns the mount point containing p, which is on dev.
+func mountTop(p string, dev uint64) string {
+	for {
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p
+		}
+		if d, err := deviceOf(parent); err != nil || d != dev {
+			return p
+		}
+		p = parent
+	}
+}
+
+func findTrashDir(file string) (*trashDir, error) {
+	dev, err := deviceOf(file)
+	if err != nil {
+		return nil, err
+	}
+	home := homeTrashDir()
+	existing := home
+	for {
+		if _, err := os.Stat(existing); err == nil || filepath.Dir(existing) == existing {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	if d, err := deviceOf(existing); err == nil && d == dev {
+		return &trashDir{dir: home}, nil
+	}
+
+	top := mountTop(file, dev)
+	uid := strconv.Itoa(os.Getuid())
+	// $topdir/.Trash must be a sticky directory, not a symbolic link.
+	if st, err := os.Lstat(filepath.Join(top, ".Trash")); err == nil && st.IsDir() && st.Mode()&fs.ModeSticky != 0 {
+		dir := filepath.Join(top, ".Trash", uid)
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return &trashDir{dir: dir, top: top}, nil
+		}
+	}
+	dir := filepath.Join(top, ".Trash-"+uid)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &trashDir{dir: dir, top: top}, nil
+}
+
+// trashDirs returns the existing trash directories of the user.
+func trashDirs() []*trashDir {
+	dirs := []*trashDir{{dir: homeTrashDir()}}
+	b, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return dirs
+	}
+	uid := strconv.Itoa(os.Getuid())
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		top := unescapeMountPath(fields[1])
+		for _, dir := range []string{filepath.Join(top, ".Trash", uid), filepath.Join(top, ".Trash-"+uid)} {
+			if st, err := os.Stat(filepath.Join(dir, "info")); err == nil && st.IsDir() && dir != dirs[0].dir {
+				dirs = append(dirs, &trashDir{dir: dir, top: top})
+			}
+		}
+	}
+	return dirs
+}
+
+// unescapeMountPath decodes octal escapes (e.g. "\040" for space) in /proc/self/mounts.
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func trashName(base string, i int) string {
+	if i == 1 {
+		return base
+	}
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	return base[:len(base)-len(ext)] + "." + strconv.Itoa(i) + ext
+}
+
+// MoveFileToTrash moves the file or directory at the absolute path file to the trash.
+func MoveFileToTrash(file string) error {
+	file = filepath.Clean(file)
+	t, err := findTrashDir(file)
+	if err != nil {
+		return err
+	}
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(t.dir, sub), 0700); err != nil {
+			return err
+		}
+	}
+	original := file
+	if t.top != "" {
+		if rel, err := filepath.Rel(t.top, file); err == nil {
+			original = rel
+		}
+	}
+
+	// The info file is created exclusively to reserve the name in the trash.
+	base := filepath.Base(file)
+	for i := 1; ; i++ {
+		name := trashName(base, i)
+		infoPath := filepath.Join(t.dir, "info", name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		dst := filepath.Join(t.dir, "files", name)
+		if _, err := os.Lstat(dst); err == nil {
+			// Keep the orphaned info file to skip the name.
+			f.Close()
+			continue
+		}
+		_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: original}).EscapedPath(), time.Now().Format(trashInfoTimeFormat))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(file, dst)
+		}
+		if err != nil {
+			os.Remove(infoPath)
+		}
+		return err
+	}
+}
+
+func readTrashInfo(t *trashDir, infoFile string) (*TrashItem, error) {
+	f, err := os.Open(infoFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	name := strings.TrimSuffix(filepath.Base(infoFile), ".trashinfo")
+	item := &TrashItem{ID: filepath.Join(t.dir, "files", name), Name: name}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "Path":
+			p, err := url.PathUnescape(value)
+			if err != nil {
+				return nil, err
+			}
+			if t.top != "" && !filepath.IsAbs(p) {
+				p = filepath.Join(t.top, p)
+			}
+			item.OriginalPath = p
+			item.Name = filepath.Base(p)
+		case "DeletionDate":
+			if tm, err := time.ParseInLocation(trashInfoTimeFormat, value, time.Local); err == nil {
+				item.DeletionTime = tm.UnixMilli()
+			}
+		}
+	}
+	if item.OriginalPath == "" {
+		return nil, errors.New("invalid trashinfo: " + infoFile)
+	}
+	st, err := os.Lstat(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	item.Size, item.IsDir = st.Size(), st.IsDir()
+	return item, scanner.Err()
+}
+
+// ListTrash returns the items in the trash directories of the user.
+func ListTrash() ([]*TrashItem, error) {
+	items := []*TrashItem{}
+	for _, t := range trashDirs() {
+		entries, err := os.ReadDir(filepath.Join(t.dir, "info"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !strings.HasSuffix(e.Name(), ".trashinfo") {
+				continue
+			}
+			if item, err := readTrashInfo(t, filepath.Join(t.dir, "info", e.Name())); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+func findTrashItem(id string) (*trashDir, *TrashItem, error) {
+	dir, name := filepath.Dir(filepath.Dir(id)), filepath.Base(id)
+	if filepath.Base(filepath.Dir(id)) == "files" {
+		for _, t := range trashDirs() {
+			if t.dir == dir {
+				item, err := readTrashInfo(t, filepath.Join(t.dir, "info", name+".trashinfo"))
+				return t, item, err
+			}
+		}
+	}
+	return nil, nil, &fs.PathError{Op: "restore", Path: id, Err: fs.ErrNotExist}
+}
+
+// RestoreFromTrash moves the item back to the original path. It fails if a file exists at the path.
+func RestoreFromTrash(id string) error {
+	t, item, err := findTrashItem(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(item.OriginalPath); err == nil {
+		return &fs.PathError{Op: "restore", Path: item.OriginalPath, Err: fs.ErrExist}
+	}
+	if err := os.MkdirAll(filepath.Dir(item.OriginalPath), 0777); err != nil {
+		return err
+	}
+	if err := os.Rename(item.ID, item.OriginalPath); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(t.dir, "info", filepath.Base(item.ID)+".trashinfo"))
+}
+
+// EmptyTrash removes all items in the trash directories of the user permanently.
+func EmptyTrash() error {
+	var result error
+	for _, t := range trashDirs() {
+		for _, sub := range []string{"files", "info"} {
+			entries, err := os.ReadDir(filepath.Join(t.dir, sub))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				result = err
+			}
+			for _, e := range entries {
+				if err := os.RemoveAll(filepath.Join(t.dir, sub, e.Name())); err != nil {
+					result = err
+				}
+			}
+		}
+		os.Remove(filepath.Join(t.dir, "directorysizes"))
+	}
+	return result
+}
+
+func (fsys *writableDirFS) MoveToTrash(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "trash", Path: name, Err: fs.ErrInvalid}
+	}
+	return MoveFileToTrash(fsys.RealPath(name))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+)
+
+const uploadOffsetHeader = "upload-offset"
+
+func httpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, fs.ErrExist):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidOp):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// serveUpload writes the request body to the file volume?upload=<path>.
+//
+// PUT (or POST) with ?offset=0 creates or replaces the file. Other offsets append the body to the file,
+// discarding the data after offset, so an interrupted upload can continue from the size returned by HEAD.
+// The size after writing is returned in the Upload-Offset header.
+func (h *FileLoader) serveUpload(res http.ResponseWriter, req *http.Request) {
+	s := h.storage(req)
+	name := req.URL.Query().Get("upload")
+	size := int64(-1)
+	stat, err := s.v.Stat(name)
+	if err == nil && stat.IsDir() {
+		http.Error(res, "is a directory", http.StatusConflict)
+		return
+	} else if err == nil {
+		size = stat.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		http.Error(res, err.Error(), httpErrorStatus(err))
+		return
+	}
+
+	if req.Method == http.MethodHead {
+		if size < 0 {
+			http.NotFound(res, req)
+			return
+		}
+		res.Header().Set(uploadOffsetHeader, strconv.FormatInt(size, 10))
+		return
+	}
+	if req.Method != http.MethodPut && req.Method != http.MethodPost {
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Like rpc, cross-site forms cannot send this content type without a CORS preflight.
+	if mt, _, _ := mime.ParseMediaType(req.Header.Get("content-type")); mt != "application/octet-stream" {
+		http.Error(res, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(req.URL.Query().Get("offset"), 10, 64)
+	if req.URL.Query().Get("offset") == "" {
+		offset, err = 0, nil
+	}
+	if err != nil || offset < 0 {
+		http.Error(res, "invalid offset", http.StatusBadRequest)
+		return
+	}
+	if offset > max(size, 0) {
+		writeUploadResult(res, http.StatusConflict, max(size, 0))
+		return
+	}
+
+	need := Write
+	flag := os.O_WRONLY
+	if offset == 0 {
+		flag |= os.O_CREATE | os.O_TRUNC
+		if size < 0 {
+			need |= Create
+		}
+	} else {
+		flag |= os.O_APPEND
+		need |= Append
+		if size > offset {
+			need |= Truncate
+		}
+	}
+	if s.Caps(path.Dir(name))&need != need {
+		http.Error(res, "forbidden", http.StatusForbidden)
+		return
+	}
+	if offset > 0 && size > offset {
+		if err := s.v.Truncate(name, offset); err != nil {
+			http.Error(res, err.Error(), httpErrorStatus(err))
+			return
+		}
+	}
+
+	w, err := s.v.OpenWriter(name, flag)
+	if err != nil {
+		http.Error(res, err.Error(), httpErrorStatus(err))
+		return
+	}
+	n, err := io.Copy(w, req.Body)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// The written data is kept to resume the upload.
+		log.Println("Failed to upload ", name, err)
+		http.Error(res, err.Error(), httpErrorStatus(err))
+		return
+	}
+	writeUploadResult(res, http.StatusOK, offset+n)
+}
+
+func writeUploadResult(res http.ResponseWriter, code int, size int64) {
+	res.Header().Set(uploadOffsetHeader, strconv.FormatInt(size, 10))
+	res.Header().Set("content-type", "application/json")
+	res.WriteHeader(code)
+	json.NewEncoder(res).Encode(map[string]int64{"size": size})
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWritableDirFS(t *testing.T) {
+	if err := checkVolume(NewWritableDirFS(t.TempDir()), "."); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRootFS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths of RootFS start with drive names")
+	}
+	dir := strings.TrimPrefix(filepath.ToSlash(t.TempDir()), "/")
+	if err := checkVolume(WrapVolume(NewRootFS()), dir); err != nil {
+		t.Error(err)
+	}
+}
+
+// checkVolume checks the behavior of the writable interfaces of v, like testing/fstest.TestFS does for fs.FS.
+// Files are created under dir, which must be an existing empty directory; they are removed at the end.
+// Operations are checked only if they are reported by Caps(v), and unreported ones must fail.
+// It returns nil if v passes, or an error listing all the problems found.
+func checkVolume(v Volume, dir string) error {
+	t := &volumeTester{v: v, dir: dir, caps: Caps(v)}
+	t.checkDir()
+	t.checkWriter()
+	t.checkTruncate()
+	t.checkMkdir()
+	t.checkRename()
+	t.checkRemove()
+	t.checkUnsupported()
+	t.cleanup()
+	return errors.Join(t.errs...)
+}
+
+type volumeTester struct {
+	v    Volume
+	dir  string
+	caps Capability
+	errs []error
+}
+
+func (t *volumeTester) errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Errorf(format, args...))
+}
+
+func (t *volumeTester) path(name string) string {
+	return path.Join(t.dir, name)
+}
+
+func (t *volumeTester) has(c Capability) bool {
+	return t.caps&c == c
+}
+
+func (t *volumeTester) write(name string, flag int, data string) error {
+	w, err := t.v.OpenWriter(t.path(name), flag)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, data)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (t *volumeTester) read(name string) (string, error) {
+	b, err := fs.ReadFile(t.v, t.path(name))
+	return string(b), err
+}
+
+func (t *volumeTester) expectContent(op, name, want string) {
+	got, err := t.read(name)
+	if err != nil {
+		t.errorf("%s: read %s: %v", op, name, err)
+		return
+	}
+	if got != want {
+		t.errorf("%s: %s contains %q, want %q", op, name, got, want)
+	}
+	if !t.has(Stat) {
+		return
+	}
+	st, err := t.v.Stat(t.path(name))
+	if err != nil {
+		t.errorf("%s: stat %s: %v", op, name, err)
+		return
+	}
+	if st.Size() != int64(len(want)) {
+		t.errorf("%s: stat %s: size %d, want %d", op, name, st.Size(), len(want))
+	}
+	if st.IsDir() {
+		t.errorf("%s: stat %s: is a directory", op, name)
+	}
+	if st.ModTime().IsZero() {
+		t.errorf("%s: stat %s: modification time is not set", op, name)
+	}
+}
+
+func (t *volumeTester) expectNotExist(op, name string) {
+	_, err := fs.Stat(t.v, t.path(name))
+	if err == nil {
+		t.errorf("%s: %s still exists", op, name)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		t.errorf("%s: stat %s: %v, want fs.ErrNotExist", op, name, err)
+	}
+}
+
+func (t *volumeTester) expectDir(op, name string) {
+	st, err := fs.Stat(t.v, t.path(name))
+	if err != nil {
+		t.errorf("%s: stat %s: %v", op, name, err)
+	} else if !st.IsDir() {
+		t.errorf("%s: %s is not a directory", op, name)
+	}
+}
+
+func (t *volumeTester) checkDir() {
+	entries, err := fs.ReadDir(t.v, t.dir)
+	if err != nil {
+		t.errorf("readdir %s: %v", t.dir, err)
+	} else if len(entries) != 0 {
+		t.errorf("readdir %s: directory is not empty", t.dir)
+	}
+}
+
+func (t *volumeTester) checkWriter() {
+	if !t.has(Write) {
+		return
+	}
+	if err := t.write("missing", os.O_WRONLY, "x"); err == nil {
+		t.errorf("OpenWriter without O_CREATE: succeeded for a missing file")
+	}
+	t.expectNotExist("OpenWriter without O_CREATE", "missing")
+
+	if err := t.write("file", os.O_WRONLY|os.O_CREATE, "hello"); err != nil {
+		t.errorf("OpenWriter O_CREATE: %v", err)
+		return
+	}
+	t.expectContent("OpenWriter O_CREATE", "file", "hello")
+
+	if err := t.write("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, "x"); err == nil {
+		t.errorf("OpenWriter O_EXCL: succeeded for an existing file")
+	}
+	t.expectContent("OpenWriter O_EXCL", "file", "hello")
+
+	// Volumes without Truncate may only replace the whole content.
+	if t.has(Truncate) {
+		if err := t.write("file", os.O_WRONLY, "J"); err != nil {
+			t.errorf("OpenWriter: %v", err)
+		}
+		t.expectContent("OpenWriter", "file", "Jello")
+	}
+
+	if t.has(Append) {
+		if err := t.write("file", os.O_WRONLY|os.O_APPEND, " world"); err != nil {
+			t.errorf("OpenWriter O_APPEND: %v", err)
+		}
+		t.expectContent("OpenWriter O_APPEND", "file", "Jello world")
+	}
+
+	if err := t.write("file", os.O_WRONLY|os.O_TRUNC, "hi"); err != nil {
+		t.errorf("OpenWriter O_TRUNC: %v", err)
+	}
+	t.expectContent("OpenWriter O_TRUNC", "file", "hi")
+}
+
+func (t *volumeTester) checkTruncate() {
+	if !t.has(Truncate) {
+		return
+	}
+	if err := t.write("trunc", os.O_WRONLY|os.O_CREATE, "abcdef"); err != nil {
+		t.errorf("Truncate: create: %v", err)
+		return
+	}
+	if err := t.v.Truncate(t.path("trunc"), 3); err != nil {
+		t.errorf("Truncate shrink: %v", err)
+	}
+	t.expectContent("Truncate shrink", "trunc", "abc")
+	if err := t.v.Truncate(t.path("trunc"), 5); err != nil {
+		t.errorf("Truncate extend: %v", err)
+	}
+	t.expectContent("Truncate extend", "trunc", "abc\x00\x00")
+	if err := t.v.Truncate(t.path("missing"), 0); err == nil {
+		t.errorf("Truncate: succeeded for a missing file")
+	}
+}
+
+func (t *volumeTester) checkMkdir() {
+	if !t.has(Mkdir) {
+		return
+	}
+	if err := t.v.Mkdir(t.path("dir"), 0777); err != nil {
+		t.errorf("Mkdir: %v", err)
+		return
+	}
+	t.expectDir("Mkdir", "dir")
+	if err := t.v.Mkdir(t.path("dir"), 0777); err == nil {
+		t.errorf("Mkdir: succeeded for an existing directory")
+	}
+	if err := t.v.Mkdir(t.path("missing/dir"), 0777); err == nil {
+		t.errorf("Mkdir: succeeded without parent directory")
+	}
+	if err := t.v.Mkdir(t.path("dir/sub"), 0777); err != nil {
+		t.errorf("Mkdir nested: %v", err)
+	}
+
+	entries, err := fs.ReadDir(t.v, t.path("dir"))
+	if err != nil {
+		t.errorf("ReadDir: %v", err)
+	} else if len(entries) != 1 || entries[0].Name() != "sub" || !entries[0].IsDir() {
+		t.errorf("ReadDir: got %v, want [sub/]", entryNames(entries))
+	}
+}
+
+func (t *volumeTester) checkRename() {
+	if !t.has(Rename) || !t.has(Write) {
+		return
+	}
+	if err := t.write("src", os.O_WRONLY|os.O_CREATE, "src"); err != nil {
+		t.errorf("Rename: create: %v", err)
+		return
+	}
+	if err := t.v.Rename(t.path("src"), t.path("dst")); err != nil {
+		t.errorf("Rename: %v", err)
+	}
+	t.expectNotExist("Rename", "src")
+	t.expectContent("Rename", "dst", "src")
+
+	if err := t.v.Rename(t.path("src"), t.path("dst2")); err == nil {
+		t.errorf("Rename: succeeded for a missing file")
+	}
+
+	if err := t.write("src", os.O_WRONLY|os.O_CREATE, "new"); err == nil {
+		if err := t.v.Rename(t.path("src"), t.path("dst")); err != nil {
+			t.errorf("Rename over existing file: %v", err)
+		}
+		t.expectContent("Rename over existing file", "dst", "new")
+	}
+
+	if !t.has(Mkdir) {
+		return
+	}
+	if err := t.v.Mkdir(t.path("rdir"), 0777); err != nil {
+		return
+	}
+	if err := t.write("rdir/f", os.O_WRONLY|os.O_CREATE, "f"); err != nil {
+		t.errorf("Rename: create: %v", err)
+		return
+	}
+	if err := t.v.Rename(t.path("rdir"), t.path("rdir2")); err != nil {
+		t.errorf("Rename directory: %v", err)
+	}
+	t.expectNotExist("Rename directory", "rdir")
+	t.expectContent("Rename directory", "rdir2/f", "f")
+}
+
+func (t *volumeTester) checkRemove() {
+	if !t.has(Remove) {
+		return
+	}
+	if t.has(Write) {
+		if err := t.write("rm", os.O_WRONLY|os.O_CREATE, "x"); err == nil {
+			if err := t.v.Remove(t.path("rm")); err != nil {
+				t.errorf("Remove file: %v", err)
+			}
+			t.expectNotExist("Remove file", "rm")
+		}
+	}
+	if err := t.v.Remove(t.path("missing")); err == nil {
+		t.errorf("Remove: succeeded for a missing file")
+	}
+	if !t.has(Mkdir) {
+		return
+	}
+	if err := t.v.Mkdir(t.path("rmdir"), 0777); err != nil {
+		return
+	}
+	if err := t.v.Mkdir(t.path("rmdir/sub"), 0777); err == nil {
+		if err := t.v.Remove(t.path("rmdir")); err == nil {
+			t.errorf("Remove: succeeded for a non-empty directory")
+		}
+		t.expectDir("Remove non-empty directory", "rmdir/sub")
+		if err := t.v.Remove(t.path("rmdir/sub")); err != nil {
+			t.errorf("Remove empty directory: %v", err)
+		}
+	}
+	if err := t.v.Remove(t.path("rmdir")); err != nil {
+		t.errorf("Remove empty directory: %v", err)
+	}
+	t.expectNotExist("Remove empty directory", "rmdir")
+}
+
+func (t *volumeTester) checkUnsupported() {
+	if !t.has(Write) {
+		if err := t.write("unsupported", os.O_WRONLY|os.O_CREATE, "x"); err == nil {
+			t.errorf("OpenWriter: succeeded but Write is not in Caps")
+		}
+	}
+	if !t.has(Mkdir) {
+		if err := t.v.Mkdir(t.path("unsupported"), 0777); err == nil {
+			t.errorf("Mkdir: succeeded but Mkdir is not in Caps")
+		}
+	}
+
+	// The other operations need an existing file to fail on.
+	if !t.has(Write) {
+		return
+	}
+	if err := t.write("unsupported", os.O_WRONLY|os.O_CREATE, "x"); err != nil {
+		t.errorf("OpenWriter O_CREATE: %v", err)
+		return
+	}
+	if !t.has(Append) {
+		if err := t.write("unsupported", os.O_WRONLY|os.O_APPEND, "y"); err == nil {
+			t.errorf("OpenWriter O_APPEND: succeeded but Append is not in Caps")
+		}
+		t.expectContent("OpenWriter O_APPEND", "unsupported", "x")
+	}
+	if !t.has(Truncate) {
+		if err := t.v.Truncate(t.path("unsupported"), 0); err == nil {
+			t.errorf("Truncate: succeeded but Truncate is not in Caps")
+		}
+		t.expectContent("Truncate", "unsupported", "x")
+	}
+	if !t.has(Rename) {
+		if err := t.v.Rename(t.path("unsupported"), t.path("unsupported2")); err == nil {
+			t.errorf("Rename: succeeded but Rename is not in Caps")
+		}
+		t.expectContent("Rename", "unsupported", "x")
+	}
+	if !t.has(Remove) {
+		if err := t.v.Remove(t.path("unsupported")); err == nil {
+			t.errorf("Remove: succeeded but Remove is not in Caps")
+		}
+		t.expectContent("Remove", "unsupported", "x")
+	}
+}
+
+// cleanup removes everything under dir, deepest first.
+func (t *volumeTester) cleanup() {
+	var paths []string
+	fs.WalkDir(t.v, t.dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && p != t.dir {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, p := range paths {
+		t.v.Remove(p)
+	}
+}
+
+func entryNames(entries []fs.DirEntry) []string {
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
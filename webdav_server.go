@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// NewWebDAVHandler serves v over WebDAV. prefix is stripped from request paths.
+// Requests with a Session (see AccessControl.Handler) are served from the storage of the session instead of v.
+// Files can not be changed if readOnly is true.
+func NewWebDAVHandler(v Volume, prefix string, readOnly bool) http.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: NewWebDAVFileSystem(v, readOnly),
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(req *http.Request, err error) {
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Println("WebDAV", req.Method, req.URL.Path, err)
+			}
+		},
+	}
+}
+
+// davFileSystem adapts a Volume to webdav.FileSystem.
+// Operations not in the capabilities of the volume containing the path are rejected with fs.ErrPermission.
+type davFileSystem struct {
+	v        Volume
+	readOnly bool
+}
+
+func NewWebDAVFileSystem(v Volume, readOnly bool) webdav.FileSystem {
+	return &davFileSystem{v: v, readOnly: readOnly}
+}
+
+func (d *davFileSystem) volume(ctx context.Context) Volume {
+	if s := SessionFromContext(ctx); s != nil {
+		return s.Storage.v
+	}
+	return d.v
+}
+
+func davName(name string) string {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (d *davFileSystem) check(v Volume, op, name string, c Capability) error {
+	caps := CapsAt(v, name)
+	if d.readOnly {
+		caps &= CapReadOnly
+	}
+	if caps&c != c {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (d *davFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name, v := davName(name), d.volume(ctx)
+	if err := d.check(v, "mkdir", name, Mkdir); err != nil {
+		return err
+	}
+	return v.Mkdir(name, perm)
+}
+
+func (d *davFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name, v := davName(name), d.volume(ctx)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		f, err := v.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return &davReadFile{File: f}, nil
+	}
+
+	need := Write
+	if flag&os.O_CREATE != 0 {
+		if _, err := v.Stat(name); errors.Is(err, fs.ErrNotExist) {
+			need |= Create
+		}
+	}
+	if flag&os.O_APPEND != 0 {
+		need |= Append
+	}
+	if err := d.check(v, "open", name, need); err != nil {
+		return nil, err
+	}
+	f := &davWriteFile{name: name}
+	if flag&(os.O_APPEND|os.O_TRUNC) == os.O_APPEND {
+		if st, err := v.Stat(name); err == nil {
+			f.pos, f.size = st.Size(), st.Size()
+		}
+	}
+	w, err := v.OpenWriter(name, flag&^os.O_RDWR|os.O_WRONLY)
+	if err != nil {
+		return nil, err
+	}
+	f.WriteCloser = w
+	return f, nil
+}
+
+// RemoveAll removes name recursively. Mount points can not be removed.
+func (d *davFileSystem) RemoveAll(ctx context.Context, name string) error {
+	name, v := davName(name), d.volume(ctx)
+	if err := d.check(v, "remove", name, Remove); err != nil {
+		return err
+	}
+	return RemoveAll(ctx, v, name)
+}
+
+func (d *davFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName, v := davName(oldName), davName(newName), d.volume(ctx)
+	if err := d.check(v, "rename", oldName, Rename); err != nil {
+		return err
+	}
+	if err := d.check(v, "rename", newName, Rename); err != nil {
+		return err
+	}
+	return v.Rename(oldName, newName)
+}
+
+func (d *davFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return d.volume(ctx).Stat(davName(name))
+}
+
+type davReadFile struct {
+	fs.File
+}
+
+func (f *davReadFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, ErrInvalidOp
+}
+
+func (f *davReadFile) Readdir(count int) ([]fs.FileInfo, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, ErrInvalidOp
+	}
+	entries, err := d.ReadDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, err
+}
+
+func (f *davReadFile) Write([]byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+// davWriteFile reports the size written so far by Stat, because some volumes create files on Close.
+type davWriteFile struct {
+	io.WriteCloser
+	name      string
+	pos, size int64
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	n, err := f.WriteCloser.Write(p)
+	f.pos += int64(n)
+	f.size = max(f.size, f.pos)
+	return n, err
+}
+
+func (f *davWriteFile) Read([]byte) (int, error) {
+	return 0, ErrInvalidOp
+}
+
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) {
+	s, ok := f.WriteCloser.(io.Seeker)
+	if !ok {
+		return 0, ErrInvalidOp
+	}
+	pos, err := s.Seek(offset, whence)
+	if err == nil {
+		f.pos = pos
+	}
+	return pos, err
+}
+
+func (f *davWriteFile) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, ErrInvalidOp
+}
+
+func (f *davWriteFile) Stat() (fs.FileInfo, error) {
+	return &httpFileInfo{name: path.Base(f.name), size: f.size, modTime: time.Now()}, nil
+}
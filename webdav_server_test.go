@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVServerShareWithoutAuth(t *testing.T) {
+	storage := NewStorage(NewMemFS())
+	access, err := NewAccessControl(&AccessConfig{Token: "secret"}, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := access.Handler(NewWebDAVHandler(storage.v, "", false))
+	for _, method := range []string{"PROPFIND", "PUT", "DELETE"} {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(method, "/volume?share=x", strings.NewReader("x")))
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", method, res.Code, http.StatusUnauthorized)
+		}
+	}
+}
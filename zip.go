@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// compressedMimeTypes are stored without compression in zip files. Most image, video and audio formats are already compressed.
+var compressedMimeTypes = map[string]bool{
+	"archive":                      true,
+	"application/zip":              true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-7z-compressed":  true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/vnd.rar":          true,
+	"application/x-rar-compressed": true,
+	"application/pdf":              true,
+}
+
+var uncompressedMediaTypes = map[string]bool{
+	"image/bmp":     true,
+	"image/tiff":    true,
+	"image/svg+xml": true,
+	"audio/wav":     true,
+	"audio/x-wav":   true,
+	"audio/midi":    true,
+}
+
+func isCompressedMimeType(mimeType string) bool {
+	t := strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	if uncompressedMediaTypes[t] {
+		return false
+	}
+	return compressedMimeTypes[t] || strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "video/") || strings.HasPrefix(t, "audio/")
+}
+
+// ZipWriter writes files of a Volume to a zip stream.
+type ZipWriter struct {
+	w *zip.Writer
+	v Volume
+	// Store disables compression of all files. Otherwise only already compressed media are stored.
+	Store bool
+}
+
+func NewZipWriter(w io.Writer, v Volume) *ZipWriter {
+	return &ZipWriter{w: zip.NewWriter(w), v: v}
+}
+
+// AddTree adds the file or directory tree at name as zipName.
+// Files which cannot be read are skipped, but errors writing the stream are returned.
+func (z *ZipWriter) AddTree(name, zipName string) error {
+	return fs.WalkDir(z.v, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Println("zip ", p, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel := "."
+		if p != name && name == "." {
+			rel = p
+		} else if p != name {
+			rel = strings.TrimPrefix(p, name+"/")
+		}
+		entryName := path.Join(zipName, rel)
+		info, err := d.Info()
+		if err != nil {
+			log.Println("zip ", p, err)
+			return nil
+		}
+		if d.IsDir() {
+			_, err := z.w.CreateHeader(&zip.FileHeader{Name: entryName + "/", Modified: info.ModTime()})
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return z.addFile(p, entryName, info)
+	})
+}
+
+func (z *ZipWriter) addFile(name, zipName string, info fs.FileInfo) error {
+	f, err := z.v.Open(name)
+	if err != nil {
+		log.Println("zip ", name, err)
+		return nil
+	}
+	defer f.Close()
+	h := &zip.FileHeader{Name: zipName, Modified: info.ModTime(), Method: zip.Deflate}
+	if z.Store || isCompressedMimeType(MimeTypeByFilename(name)) {
+		h.Method = zip.Store
+	}
+	h.SetMode(info.Mode())
+	w, err := z.w.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (z *ZipWriter) Close() error {
+	return z.w.Close()
+}
+
+// serveZip streams the directory tree volume?download=<path>&mode=zip as a zip file.
+// If path=<name> parameters are given, only the named entries of the directory are included.
+// store=1 disables compression.
+func (h *FileLoader) serveZip(res http.ResponseWriter, req *http.Request, v Volume, dir string) {
+	q := req.URL.Query()
+	names := []string{}
+	for _, p := range q["path"] {
+		if p = strings.TrimPrefix(path.Clean("/"+p), "/"); p != "" {
+			names = append(names, p)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, ".")
+	}
+	zipName := path.Base(dir)
+	if zipName == "." || zipName == "/" {
+		zipName = "files"
+	}
+	if len(names) == 1 && names[0] != "." {
+		zipName = path.Base(names[0])
+	}
+
+	res.Header().Set("content-type", "application/zip")
+	res.Header().Set("content-disposition", "attachment; filename*=UTF-8''"+url.PathEscape(zipName+".zip"))
+	z := NewZipWriter(res, v)
+	z.Store = q.Get("store") == "1"
+	for _, name := range names {
+		entryName := name
+		if name == "." {
+			entryName = zipName
+		}
+		if err := z.AddTree(path.Join(dir, name), entryName); err != nil {
+			// The response cannot be changed after streaming started.
+			log.Println("zip ", dir, err)
+			return
+		}
+	}
+	if err := z.Close(); err != nil {
+		log.Println("zip ", dir, err)
+	}
+}